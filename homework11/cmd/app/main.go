@@ -9,42 +9,62 @@ import (
 	"sync/atomic"
 )
 
-func testGoRoutines(col *ds.Collection) {
+const (
+	benchmarkShards = 16
+	// Writers and readers run side by side for this long
+	benchmarkDuration = time.Second
+	putWorkers        = 8
+	listWorkers       = 2
+	// Keys are drawn from this range, so the collection grows up to it
+	benchmarkKeys = 10000
+)
+
+func testGoRoutines(name string, col *ds.Collection) {
 	var wg sync.WaitGroup
-	rand.Seed(time.Now().Unix())
-	startTime := time.Now()
+	var stop atomic.Bool
 
 	var putCounter int64 = 0
 	var listCounter int64 = 0
 
-	for range 1000 {
+	for range putWorkers {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if rand.Intn(10) % 2 == 0 {
+			for !stop.Load() {
 				d := ds.Document{Fields: make(map[string]ds.DocumentField)}
 				d.Fields["key1"] = ds.DocumentField{Type: ds.DocumentFieldTypeString,
-					Value: fmt.Sprintf("key%d", rand.Intn(1000))}
+					Value: fmt.Sprintf("key%d", rand.Intn(benchmarkKeys))}
 				d.Fields["val"] = ds.DocumentField{Type: ds.DocumentFieldTypeString,
 					Value: fmt.Sprintf("val%d", rand.Intn(1000))}
 				col.Put(d)
 				atomic.AddInt64(&putCounter, 1)
-			} else {
+			}
+		}()
+	}
+	for range listWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !stop.Load() {
 				col.List()
 				atomic.AddInt64(&listCounter, 1)
-
 			}
 		}()
 	}
 
+	startTime := time.Now()
+	time.Sleep(benchmarkDuration)
+	stop.Store(true)
 	wg.Wait()
 	elapsedTime := time.Since(startTime)
 
 	// Print performance metrics
-	fmt.Printf("Performance Metrics:\n")
-	fmt.Printf("  Total Put Calls: %d\n", atomic.LoadInt64(&putCounter))
-	fmt.Printf("  Total List Calls: %d\n", atomic.LoadInt64(&listCounter))
+	fmt.Printf("Performance Metrics (%s, %d puts and %d lists in parallel):\n", name, putWorkers, listWorkers)
+	fmt.Printf("  Total Put Calls: %d\n", putCounter)
+	fmt.Printf("  Total List Calls: %d\n", listCounter)
 	fmt.Printf("  Total Execution Time: %v\n", elapsedTime)
+	fmt.Printf("  Put Throughput: %.0f ops/s\n", float64(putCounter)/elapsedTime.Seconds())
+	fmt.Printf("  List Throughput: %.0f ops/s\n", float64(listCounter)/elapsedTime.Seconds())
 }
 
 func main() {
@@ -71,10 +91,18 @@ func main() {
 	}
 	col, ok := restoredStore.GetCollection("key1")
 	if ok {
-		testGoRoutines(col)
+		testGoRoutines("single lock", col)
 		//fmt.Println(col.List())
 	}
 
+	// Same workload against a collection split into independently locked shards
+	cfg2 := ds.CollectionConfig{PrimaryKey: "key1", Shards: benchmarkShards}
+	ok, col2 := restoredStore.CreateCollection("sharded", &cfg2)
+	if ok {
+		col2.Put(d1)
+		testGoRoutines(fmt.Sprintf("%d shards", benchmarkShards), col2)
+	}
+
 	fmt.Println("Done")
 
 }
@@ -2,6 +2,8 @@ package documentstore
 
 import (
 	"encoding/json"
+	"hash/fnv"
+	"slices"
 	"sync"
 )
 
@@ -9,19 +11,86 @@ type Collection struct {
 	docs map[string]Document
 	config CollectionConfig
 	mx sync.RWMutex
+	// Used instead of `docs` and `mx` when the collection is sharded
+	shards []*collectionShard
 }
 
 type CollectionConfig struct {
 	PrimaryKey string
+	// Shards splits documents by hash of the primary key into this many maps,
+	// each with its own lock. 0 or 1 keeps a single lock for the collection
+	Shards int `json:",omitempty"`
 }
 
-func (s Collection) MarshalJSON() ([]byte, error) {
+type collectionShard struct {
+	docs map[string]Document
+	mx sync.RWMutex
+}
+
+func newCollection(cfg CollectionConfig) *Collection {
+	col := &Collection{config: cfg}
+	col.setDocs(make(map[string]Document))
+	return col
+}
+
+// setDocs puts the documents into `docs` or spreads them over the shards.
+func (s *Collection) setDocs(docs map[string]Document) {
+	if s.config.Shards <= 1 {
+		s.docs = docs
+		return
+	}
+	s.docs = nil
+	s.shards = make([]*collectionShard, s.config.Shards)
+	for i := range s.shards {
+		s.shards[i] = &collectionShard{docs: make(map[string]Document)}
+	}
+	for key, doc := range docs {
+		_, shard := s.shardFor(key)
+		shard[key] = doc
+	}
+}
+
+// shardFor returns the lock and the map holding the key.
+func (s *Collection) shardFor(key string) (*sync.RWMutex, map[string]Document) {
+	if len(s.shards) == 0 {
+		return &s.mx, s.docs
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	shard := s.shards[h.Sum32()%uint32(len(s.shards))]
+	return &shard.mx, shard.docs
+}
+
+// allDocs returns a copy of the documents, taken under the lock of the
+// collection or of one shard at a time.
+func (s *Collection) allDocs() map[string]Document {
+	if len(s.shards) == 0 {
+		s.mx.RLock()
+		defer s.mx.RUnlock()
+		docs := make(map[string]Document, len(s.docs))
+		for key, doc := range s.docs {
+			docs[key] = doc
+		}
+		return docs
+	}
+	docs := make(map[string]Document)
+	for _, shard := range s.shards {
+		shard.mx.RLock()
+		for key, doc := range shard.docs {
+			docs[key] = doc
+		}
+		shard.mx.RUnlock()
+	}
+	return docs
+}
+
+func (s *Collection) MarshalJSON() ([]byte, error) {
 	type Alias struct {
 		Docs map[string]Document `json:"docs"`
 		Config CollectionConfig `json:"config"`
 	}
 	alias := Alias{
-		Docs: s.docs,
+		Docs: s.allDocs(),
 		Config: s.config,
 	}
 
@@ -41,9 +110,10 @@ func (s *Collection) UnmarshalJSON(data []byte) error {
 	}
 
 	// Set private field manually
-	s.docs = alias.Docs
 	s.config = alias.Config
 	s.mx = sync.RWMutex{}
+	s.shards = nil
+	s.setDocs(alias.Docs)
 	return nil
 }
 
@@ -54,42 +124,59 @@ func (s *Collection) Put(doc Document) {
 	if !ok {
 		return
 	}
-	s.mx.Lock()
-	defer func() {
-		s.mx.Unlock()
-	}()
 	if keyField.Type != DocumentFieldTypeString {
 		return
 	}
 	key, isString := keyField.Value.(string)
 	if isString && len(key) > 0 {
-		s.docs[key] = doc
+		mx, docs := s.shardFor(key)
+		mx.Lock()
+		defer func() {
+			mx.Unlock()
+		}()
+		docs[key] = doc
 	}
 }
 
 func (s *Collection) Get(key string) (*Document, bool) {
-	s.mx.RLock()
+	mx, docs := s.shardFor(key)
+	mx.RLock()
 	defer func() {
-		s.mx.RUnlock()
+		mx.RUnlock()
 	}()
-	doc, ok := s.docs[key]
+	doc, ok := docs[key]
 	return &doc, ok
 }
 
 func (s *Collection) Delete(key string) bool {
-	s.mx.Lock()
+	mx, docs := s.shardFor(key)
+	mx.Lock()
 	defer func() {
-		s.mx.Unlock()
+		mx.Unlock()
 	}()
-	_, ok := s.docs[key]
+	_, ok := docs[key]
 	if !ok {
 		return false
 	}
-	delete(s.docs, key)
+	delete(docs, key)
 	return true
 }
 
+// List of a sharded collection locks one shard at a time, so it is not a
+// point in time snapshot of the whole collection.
 func (s *Collection) List() []Document {
+	if len(s.shards) > 0 {
+		var values []Document
+		for _, shard := range s.shards {
+			shard.mx.RLock()
+			values = slices.Grow(values, len(shard.docs))
+			for _, doc := range shard.docs {
+				values = append(values, doc)
+			}
+			shard.mx.RUnlock()
+		}
+		return values
+	}
 	values := make([]Document, 0, len(s.docs))
 	s.mx.RLock()
 	defer func() {
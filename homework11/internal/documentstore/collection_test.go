@@ -2,6 +2,8 @@ package documentstore
 
 import (
 	"encoding/json"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -19,7 +21,7 @@ func TestMarshalJSON(t *testing.T) {
 		config: CollectionConfig{PrimaryKey: "name"},
 	}
 
-	data, err := json.Marshal(collection)
+	data, err := json.Marshal(&collection)
 
 	assert.NoError(t, err, "unexpected error during marshalling")
 
@@ -46,7 +48,7 @@ func TestUnmarshalJSON(t *testing.T) {
 		config: CollectionConfig{PrimaryKey: "name"},
 	}
 
-	assert.Equal(t, expectedCollection, collection, "unmarshalled collection does not match the expected result")
+	assert.Equal(t, &expectedCollection, &collection, "unmarshalled collection does not match the expected result")
 }
 
 func TestPut(t *testing.T) {
@@ -155,4 +157,47 @@ func TestList(t *testing.T) {
 	for _, doc := range expectedDocs {
 		assert.Contains(t, docs, doc, "expected document %+v not found in the result", doc)
 	}
-}
\ No newline at end of file
+}
+func TestShardedCollection(t *testing.T) {
+	collection := newCollection(CollectionConfig{PrimaryKey: "id", Shards: 4})
+	assert.Len(t, collection.shards, 4, "collection should be split into 4 shards")
+
+	for i := range 20 {
+		collection.Put(Document{Fields: map[string]DocumentField{
+			"id": {Type: DocumentFieldTypeString, Value: fmt.Sprintf("key%d", i)},
+		}})
+	}
+	assert.Len(t, collection.List(), 20, "List should merge documents from all shards")
+
+	doc, ok := collection.Get("key7")
+	assert.True(t, ok, "document with key 'key7' should exist")
+	assert.Equal(t, "key7", doc.Fields["id"].Value)
+	assert.True(t, collection.Delete("key7"), "expected key 'key7' to be deleted successfully")
+	_, ok = collection.Get("key7")
+	assert.False(t, ok, "key 'key7' should no longer exist in the collection")
+
+	data, err := json.Marshal(collection)
+	assert.NoError(t, err, "unexpected error during marshalling")
+	var restored Collection
+	assert.NoError(t, json.Unmarshal(data, &restored), "unexpected error during unmarshalling")
+	assert.Len(t, restored.shards, 4, "restored collection should keep its shards")
+	assert.Len(t, restored.List(), 19, "restored collection should have all documents")
+}
+
+func TestShardedCollectionConcurrentPut(t *testing.T) {
+	collection := newCollection(CollectionConfig{PrimaryKey: "id", Shards: 8})
+
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			collection.Put(Document{Fields: map[string]DocumentField{
+				"id": {Type: DocumentFieldTypeString, Value: fmt.Sprintf("key%d", i)},
+			}})
+			collection.List()
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, collection.List(), 100, "no put should be lost")
+}
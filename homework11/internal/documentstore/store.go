@@ -10,12 +10,12 @@ import (
 var logger = slog.Default()
 
 type Store struct {
-	collections map[string]Collection
+	collections map[string]*Collection
 }
 
 func (s *Store) MarshalJSON() ([]byte, error) {
 	type Alias struct {
-		Collections map[string]Collection `json:"collections"`
+		Collections map[string]*Collection `json:"collections"`
 	}
 	alias := Alias{
 		Collections: s.collections,
@@ -27,7 +27,7 @@ func (s *Store) MarshalJSON() ([]byte, error) {
 func (s *Store) UnmarshalJSON(data []byte) error {
 	// Create an alias or temporary struct for unmarshalling
 	alias := struct {
-		Collections map[string]Collection `json:"collections"`
+		Collections map[string]*Collection `json:"collections"`
 	}{}
 
 	// Unmarshal into the alias
@@ -41,7 +41,7 @@ func (s *Store) UnmarshalJSON(data []byte) error {
 }
 
 func NewStore() *Store {
-	return &Store{collections: make(map[string]Collection)}
+	return &Store{collections: make(map[string]*Collection)}
 }
 
 func (s *Store) CreateCollection(name string, cfg *CollectionConfig) (bool, *Collection) {
@@ -51,7 +51,7 @@ func (s *Store) CreateCollection(name string, cfg *CollectionConfig) (bool, *Col
 		logger.Warn("CollectionConfig is nil, cannot create collection", "name", name)
 		return false, nil
 	}
-	col := Collection{docs: make(map[string]Document), config: *cfg}
+	col := newCollection(*cfg)
 
 	_, exists := s.collections[name]
 	if exists {
@@ -61,7 +61,7 @@ func (s *Store) CreateCollection(name string, cfg *CollectionConfig) (bool, *Col
 
 	s.collections[name] = col
 	logger.Info("Collection created", "name", name)
-	return true, &col
+	return true, col
 }
 
 func (s *Store) GetCollection(name string) (*Collection, bool) {
@@ -72,7 +72,7 @@ func (s *Store) GetCollection(name string) (*Collection, bool) {
 		return nil, false
 	}
 	logger.Info("Collection retrieved", "name", name)
-	return &col, true
+	return col, true
 }
 
 func (s *Store) DeleteCollection(name string) bool {
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	cmds "hw12/internal/commands"
+)
+
+const (
+	defaultPoolSize    = 4
+	defaultDialTimeout = 5 * time.Second
+)
+
+var ErrClientClosed = errors.New("client closed")
+
+// Options configure a Client, zero values pick the defaults.
+type Options struct {
+	PoolSize    int           // Connections used in turn, 4 by default
+	DialTimeout time.Duration // Limits every connection attempt, 5s by default
+}
+
+// Client talks to the server over a pool of connections, which are dialed on
+// first use and redialed once they break. It's safe for concurrent use.
+// Transactions and watches need a connection of their own, so they aren't
+// available through it.
+type Client struct {
+	addr string
+	opts Options
+
+	mx     sync.Mutex
+	conns  []*Conn // Nil until first used
+	next   int
+	closed bool
+}
+
+// New doesn't connect yet, the first request does.
+func New(addr string, opts Options) *Client {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = defaultPoolSize
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = defaultDialTimeout
+	}
+	return &Client{addr: addr, opts: opts, conns: make([]*Conn, opts.PoolSize)}
+}
+
+// Collection doesn't check that the collection exists, requests to a
+// missing one fail with ErrCollectionNotFound.
+func (c *Client) Collection(name string) *Collection {
+	return &Collection{client: c, name: name}
+}
+
+// CreateCollection returns ErrCollectionAlreadyExists if the
+// name is taken.
+func (c *Client) CreateCollection(ctx context.Context, name string, cfg CollectionConfig) (*Collection, error) {
+	req := &cmds.CreateCollectionCommandRequestPayload{Name: name, PrimaryKey: cfg.PrimaryKey}
+	if cfg.TTL > 0 {
+		req.TTL = cfg.TTL.String()
+	}
+	err := c.do(ctx, cmds.CreateCollectionCommandName, req, &cmds.CreateCollectionCommandResponsePayload{})
+	if err != nil {
+		return nil, err
+	}
+	return c.Collection(name), nil
+}
+
+// DropCollection returns ErrCollectionNotFound if there is no
+// such collection.
+func (c *Client) DropCollection(ctx context.Context, name string) error {
+	resp := &cmds.DropCollectionCommandResponsePayload{}
+	err := c.do(ctx, cmds.DropCollectionCommandName, &cmds.DropCollectionCommandRequestPayload{Name: name}, resp)
+	if err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+	}
+	return nil
+}
+
+// ListCollections returns the collection names in sorted order.
+func (c *Client) ListCollections(ctx context.Context) ([]string, error) {
+	resp := &cmds.ListCollectionsCommandResponsePayload{}
+	err := c.do(ctx, cmds.ListCollectionsCommandName, nil, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Value, nil
+}
+
+// Close closes the pool, requests in flight fail with ErrClosed.
+func (c *Client) Close() error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.closed = true
+	for i, conn := range c.conns {
+		if conn != nil {
+			conn.Close()
+			c.conns[i] = nil
+		}
+	}
+	return nil
+}
+
+// conn takes the pool's connections in turn and replaces a broken one.
+func (c *Client) conn(ctx context.Context) (*Conn, error) {
+	c.mx.Lock()
+	if c.closed {
+		c.mx.Unlock()
+		return nil, ErrClientClosed
+	}
+	slot := c.next
+	c.next = (c.next + 1) % len(c.conns)
+	conn := c.conns[slot]
+	c.mx.Unlock()
+	if conn != nil && conn.Err() == nil {
+		return conn, nil
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, c.opts.DialTimeout)
+	defer cancel()
+	fresh, err := Dial(dialCtx, c.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.closed {
+		fresh.Close()
+		return nil, ErrClientClosed
+	}
+	// Another request may have replaced the connection in the meantime
+	if current := c.conns[slot]; current != conn && current != nil && current.Err() == nil {
+		fresh.Close()
+		return current, nil
+	}
+	c.conns[slot] = fresh
+	return fresh, nil
+}
+
+// do sends req as the payload and decodes the response into resp. Reads are
+// retried once on a new connection if the one they were sent over broke,
+// writes aren't, as the server may have applied them already.
+func (c *Client) do(ctx context.Context, command string, req interface{}, resp interface{}) error {
+	var payload []byte
+	if req != nil {
+		var err error
+		payload, err = json.Marshal(req)
+		if err != nil {
+			return fmt.Errorf("error marshalling payload: %w", err)
+		}
+	}
+
+	var raw []byte
+	for attempt := 0; ; attempt++ {
+		conn, err := c.conn(ctx)
+		if err != nil {
+			return err
+		}
+		raw, err = conn.Do(ctx, command, payload)
+		if errors.Is(err, ErrClosed) && attempt == 0 && readOnly(command) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		break
+	}
+
+	err := json.Unmarshal(raw, resp)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling response: %w", err)
+	}
+	return nil
+}
+
+func readOnly(command string) bool {
+	switch command {
+	case cmds.GetCommandName, cmds.ListCommandName, cmds.QueryCommandName,
+		cmds.ListCollectionsCommandName, cmds.DescribeCollectionCommandName:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	store "hw12/internal/documentstore"
+	"hw12/internal/protocol"
+	"hw12/internal/server"
+)
+
+// storeServer serves an in-memory store through the server's own handlers.
+func storeServer(t *testing.T) string {
+	srv := server.New(store.NewStore())
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.HandleConnection(conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func person(id string, age float64) Document {
+	return Document{Fields: map[string]DocumentField{
+		"id":  {Type: DocumentFieldTypeString, Value: id},
+		"age": {Type: DocumentFieldTypeNumber, Value: age},
+	}}
+}
+
+func TestClientCollection(t *testing.T) {
+	ctx := context.Background()
+	c := New(storeServer(t), Options{PoolSize: 2})
+	defer c.Close()
+
+	col, err := c.CreateCollection(ctx, "people", CollectionConfig{PrimaryKey: "id"})
+	assert.NoError(t, err)
+	_, err = c.CreateCollection(ctx, "people", CollectionConfig{PrimaryKey: "id"})
+	assert.ErrorIs(t, err, ErrCollectionAlreadyExists, "server errors should unwrap to store errors")
+
+	for i := 0; i < listPageSize+5; i++ {
+		rev, err := col.Put(ctx, person(fmt.Sprintf("p%04d", i), float64(i%50)))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(i+1), rev, "Put should return the assigned revision")
+	}
+	_, err = col.Put(ctx, Document{Fields: map[string]DocumentField{}})
+	assert.ErrorIs(t, err, ErrMissingPrimaryKey)
+
+	doc, err := col.Get(ctx, "p0007")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(7), doc.Fields["age"].Value, "field values should survive the round trip")
+	assert.Equal(t, DocumentFieldTypeNumber, doc.Fields["age"].Type, "field types should survive the round trip")
+
+	docs, err := col.List(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, docs, listPageSize+5, "List should follow cursors to the last page")
+
+	assert.NoError(t, col.Delete(ctx, "p0007"))
+	_, err = col.Get(ctx, "p0007")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+	assert.ErrorIs(t, col.Delete(ctx, "p0007"), ErrDocumentNotFound)
+
+	_, err = col.Query(ctx, "age", QueryParams{})
+	assert.ErrorIs(t, err, ErrIndexNotFound)
+	assert.NoError(t, col.CreateIndex(ctx, "age"))
+	docs, err = col.Query(ctx, "age", QueryParams{MinValue: NumberValue(48)})
+	assert.NoError(t, err)
+	assert.Len(t, docs, 40, "query should return documents within the bounds")
+
+	_, err = c.Collection("missing").Get(ctx, "p0001")
+	assert.ErrorIs(t, err, ErrCollectionNotFound)
+}
+
+func TestClientReconnects(t *testing.T) {
+	// When set the connection breaks with the next request in flight
+	var breakNext atomic.Bool
+	addr := fakeServer(t, func(f protocol.Frame, respond func(protocol.Frame), conn net.Conn) {
+		if breakNext.CompareAndSwap(true, false) {
+			conn.Close()
+			return
+		}
+		respond(protocol.Frame{Kind: protocol.FrameResponse, Command: f.Command, RequestID: f.RequestID, Payload: []byte(`{"ok":true}`)})
+	})
+	ctx := context.Background()
+	c := New(addr, Options{PoolSize: 1})
+	defer c.Close()
+
+	assert.NoError(t, c.Collection("people").Delete(ctx, "a"), "the first request should get through")
+
+	breakNext.Store(true)
+	err := c.Collection("people").Delete(ctx, "a")
+	assert.ErrorIs(t, err, ErrClosed, "writes should not be retried")
+	assert.NoError(t, c.Collection("people").Delete(ctx, "a"), "the broken connection should be replaced")
+
+	breakNext.Store(true)
+	_, err = c.ListCollections(ctx)
+	assert.NoError(t, err, "reads should be retried on a new connection")
+
+	c.Close()
+	_, err = c.ListCollections(ctx)
+	assert.ErrorIs(t, err, ErrClientClosed)
+}
+
+func TestClientContext(t *testing.T) {
+	addr := fakeServer(t, func(f protocol.Frame, respond func(protocol.Frame), _ net.Conn) {
+		// Only `list_collections` comes without a payload
+		if len(f.Payload) == 0 {
+			return
+		}
+		respond(protocol.Frame{Kind: protocol.FrameResponse, Command: f.Command, RequestID: f.RequestID, Payload: []byte(`{"ok":true}`)})
+	})
+	c := New(addr, Options{PoolSize: 1})
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.ListCollections(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "a request without a response should time out")
+
+	err = c.Collection("people").Delete(context.Background(), "a")
+	assert.NoError(t, err, "the connection should stay usable after a timeout")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = c.Collection("people").Get(ctx, "a")
+	assert.ErrorIs(t, err, context.Canceled)
+}
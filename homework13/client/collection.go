@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	cmds "hw12/internal/commands"
+)
+
+// List fetches documents in pages of this size.
+const listPageSize = 1000
+
+// Collection mirrors the store's Collection on the server and returns the
+// same errors, see ServerError.
+type Collection struct {
+	client *Client
+	name   string
+}
+
+func (c *Collection) Name() string {
+	return c.name
+}
+
+// Put returns the revision the server assigned to the document.
+func (c *Collection) Put(ctx context.Context, doc Document) (uint64, error) {
+	resp := &cmds.PutCommandResponsePayload{}
+	err := c.client.do(ctx, cmds.PutCommandName,
+		&cmds.PutCommandRequestPayload{Collection: c.name, Document: doc}, resp)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Revision, nil
+}
+
+// PutIf only writes the document if the stored one has the given revision, 0
+// meaning it must not exist yet, and returns the new revision.
+func (c *Collection) PutIf(ctx context.Context, doc Document, revision uint64) (uint64, error) {
+	resp := &cmds.PutCommandResponsePayload{}
+	err := c.client.do(ctx, cmds.PutCommandName,
+		&cmds.PutCommandRequestPayload{Collection: c.name, Document: doc, Revision: &revision}, resp)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Revision, nil
+}
+
+func (c *Collection) Get(ctx context.Context, key string) (*Document, error) {
+	resp := &cmds.GetCommandResponsePayload{}
+	err := c.client.do(ctx, cmds.GetCommandName, &cmds.GetCommandRequestPayload{Collection: c.name, Key: key}, resp)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Ok || resp.Document == nil {
+		return nil, fmt.Errorf("%w: %s", ErrDocumentNotFound, key)
+	}
+	return resp.Document, nil
+}
+
+func (c *Collection) Delete(ctx context.Context, key string) error {
+	return c.delete(ctx, &cmds.DeleteCommandRequestPayload{Collection: c.name, Key: key})
+}
+
+func (c *Collection) DeleteIf(ctx context.Context, key string, revision uint64) error {
+	return c.delete(ctx, &cmds.DeleteCommandRequestPayload{Collection: c.name, Key: key, Revision: &revision})
+}
+
+func (c *Collection) delete(ctx context.Context, req *cmds.DeleteCommandRequestPayload) error {
+	resp := &cmds.DeleteCommandResponsePayload{}
+	err := c.client.do(ctx, cmds.DeleteCommandName, req, resp)
+	if err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return fmt.Errorf("%w: %s", ErrDocumentNotFound, req.Key)
+	}
+	return nil
+}
+
+// List returns all documents ordered by primary key. It reads them page by
+// page, so writes made meanwhile may or may not be seen.
+func (c *Collection) List(ctx context.Context) ([]Document, error) {
+	docs := []Document{}
+	req := &cmds.ListCommandRequestPayload{Collection: c.name, Limit: listPageSize}
+	for {
+		resp := &cmds.ListCommandResponsePayload{}
+		err := c.client.do(ctx, cmds.ListCommandName, req, resp)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, resp.Value...)
+		if resp.NextCursor == "" {
+			return docs, nil
+		}
+		req.Cursor = resp.NextCursor
+	}
+}
+
+// Query needs an index on fieldName, as with the store's Collection.Query.
+func (c *Collection) Query(ctx context.Context, fieldName string, params QueryParams) ([]Document, error) {
+	resp := &cmds.QueryCommandResponsePayload{}
+	err := c.client.do(ctx, cmds.QueryCommandName,
+		&cmds.QueryCommandRequestPayload{Collection: c.name, Field: fieldName, Params: params}, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Value, nil
+}
+
+func (c *Collection) CreateIndex(ctx context.Context, fieldNames ...string) error {
+	return c.client.do(ctx, cmds.CreateIndexCommandName,
+		&cmds.CreateIndexCommandRequestPayload{Collection: c.name, Fields: fieldNames},
+		&cmds.CreateIndexCommandResponsePayload{})
+}
+
+func (c *Collection) CreateUniqueIndex(ctx context.Context, fieldNames ...string) error {
+	return c.client.do(ctx, cmds.CreateIndexCommandName,
+		&cmds.CreateIndexCommandRequestPayload{Collection: c.name, Fields: fieldNames, Unique: true},
+		&cmds.CreateIndexCommandResponsePayload{})
+}
+
+func (c *Collection) DeleteIndex(ctx context.Context, fieldNames ...string) error {
+	resp := &cmds.DropIndexCommandResponsePayload{}
+	err := c.client.do(ctx, cmds.DropIndexCommandName,
+		&cmds.DropIndexCommandRequestPayload{Collection: c.name, Fields: fieldNames}, resp)
+	if err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return fmt.Errorf("%w: %s", ErrIndexNotFound, strings.Join(fieldNames, ","))
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	cmds "hw12/internal/commands"
+	"hw12/internal/protocol"
+)
+
+var ErrClosed = errors.New("connection closed")
+
+// ServerError is an error the server returned for a request. The connection
+// stays usable after it.
+type ServerError struct {
+	Command string
+	Code    string // Set for store errors, see commands.ErrorPayload
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Command, e.Message)
+}
+
+// Unwrap returns the store error the server reported, so callers can check
+// errors.Is(err, ErrUniqueConstraint) as with a local collection.
+func (e *ServerError) Unwrap() error {
+	p := &cmds.ErrorPayload{Code: e.Code}
+	return p.Err()
+}
+
+// decodeError reads an error frame payload. Servers that send a bare message
+// are understood too.
+func decodeError(payload []byte) *cmds.ErrorPayload {
+	p := &cmds.ErrorPayload{}
+	if err := json.Unmarshal(payload, p); err != nil || p.Message == "" {
+		return &cmds.ErrorPayload{Message: string(payload)}
+	}
+	return p
+}
+
+// Conn is a framed connection to the server. It's safe for concurrent use:
+// requests from different goroutines are pipelined and responses are matched
+// to them by request id, so a slow request doesn't hold up the others.
+// Requests in flight at the same time may run on the server in any order.
+//
+// Conn doesn't deliver watch events, they are dropped.
+type Conn struct {
+	conn net.Conn
+	wmx  sync.Mutex
+	w    *bufio.Writer
+
+	mx      sync.Mutex
+	nextID  uint32
+	pending map[uint32]chan protocol.Frame
+	err     error // Why the connection stopped, nil while it's open
+}
+
+// Dial connects to the server and switches the connection to frames. The
+// context limits only the dial and the handshake.
+func Dial(ctx context.Context, addr string) (*Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting: %w", err)
+	}
+	return newConn(ctx, conn)
+}
+
+func newConn(ctx context.Context, conn net.Conn) (*Conn, error) {
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	// Cancelling the context interrupts the handshake through the deadline
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	err := protocol.Handshake(r, w, protocol.FrameVersion)
+	if !stop() {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	c := &Conn{conn: conn, w: w, pending: make(map[uint32]chan protocol.Frame)}
+	go c.readLoop(r)
+	return c, nil
+}
+
+// Do sends a request and waits for its response payload. Errors returned by
+// the server are *ServerError, errors of the connection wrap ErrClosed. When
+// the context is done first its error is returned, but the server may still
+// run the request.
+func (c *Conn) Do(ctx context.Context, command string, payload []byte) ([]byte, error) {
+	commandID, ok := protocol.CommandID(command)
+	if !ok {
+		return nil, fmt.Errorf("unknown command %q", command)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Buffered so readLoop never waits for us
+	ch := make(chan protocol.Frame, 1)
+	c.mx.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mx.Unlock()
+		return nil, err
+	}
+	c.nextID++
+	requestID := c.nextID
+	c.pending[requestID] = ch
+	c.mx.Unlock()
+
+	c.wmx.Lock()
+	// A frame written partially breaks the stream, so a timed out write
+	// stops the connection
+	deadline, _ := ctx.Deadline()
+	c.conn.SetWriteDeadline(deadline)
+	err := protocol.WriteFrame(c.w, protocol.Frame{
+		Kind:      protocol.FrameRequest,
+		Command:   commandID,
+		RequestID: requestID,
+		Payload:   payload,
+	})
+	if err == nil {
+		err = c.w.Flush()
+	}
+	c.wmx.Unlock()
+	if err != nil {
+		c.fail(err)
+	}
+
+	var f protocol.Frame
+	select {
+	case f, ok = <-ch:
+		if !ok {
+			return nil, c.Err()
+		}
+	case <-ctx.Done():
+		// The response is dropped if it comes later
+		c.mx.Lock()
+		delete(c.pending, requestID)
+		c.mx.Unlock()
+		return nil, ctx.Err()
+	}
+	if f.Kind == protocol.FrameError {
+		p := decodeError(f.Payload)
+		return nil, &ServerError{Command: command, Code: p.Code, Message: p.Message}
+	}
+	return f.Payload, nil
+}
+
+// Err returns nil while the connection is open and the reason it stopped
+// afterwards.
+func (c *Conn) Err() error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.err
+}
+
+// Close fails pending requests with ErrClosed.
+func (c *Conn) Close() error {
+	c.fail(nil)
+	return nil
+}
+
+func (c *Conn) readLoop(r *bufio.Reader) {
+	for {
+		f, err := protocol.ReadFrame(r)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		if f.Kind == protocol.FrameEvent {
+			continue
+		}
+
+		c.mx.Lock()
+		ch, ok := c.pending[f.RequestID]
+		delete(c.pending, f.RequestID)
+		c.mx.Unlock()
+		if ok {
+			ch <- f
+		} else if f.Kind == protocol.FrameError {
+			// Not a response to any request, e.g. the server couldn't read a frame
+			c.fail(errors.New(decodeError(f.Payload).Message))
+			return
+		}
+	}
+}
+
+// fail stops the connection once, the first reason wins.
+func (c *Conn) fail(reason error) {
+	c.mx.Lock()
+	if c.err == nil {
+		c.err = ErrClosed
+		if reason != nil {
+			c.err = fmt.Errorf("%w: %w", ErrClosed, reason)
+		}
+		for id, ch := range c.pending {
+			close(ch)
+			delete(c.pending, id)
+		}
+	}
+	c.mx.Unlock()
+	c.conn.Close()
+}
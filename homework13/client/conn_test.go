@@ -0,0 +1,151 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	cmds "hw12/internal/commands"
+	"hw12/internal/protocol"
+)
+
+// fakeServer accepts framed connections and hands every request to handle,
+// which writes responses through respond in any order it likes.
+func fakeServer(t *testing.T, handle func(f protocol.Frame, respond func(protocol.Frame), conn net.Conn)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveFake(conn, handle)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func serveFake(conn net.Conn, handle func(f protocol.Frame, respond func(protocol.Frame), conn net.Conn)) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	line, _ := protocol.ReadLine(r)
+	fmt.Fprintf(conn, "%s\n", line)
+
+	var wmx sync.Mutex
+	respond := func(f protocol.Frame) {
+		wmx.Lock()
+		defer wmx.Unlock()
+		protocol.WriteFrame(conn, f)
+	}
+	for {
+		f, err := protocol.ReadFrame(r)
+		if err != nil {
+			return
+		}
+		handle(f, respond, conn)
+	}
+}
+
+func TestConnMatchesResponsesByID(t *testing.T) {
+	const requests = 20
+	// Hold the responses until every request arrived, then answer backwards
+	var received []protocol.Frame
+	addr := fakeServer(t, func(f protocol.Frame, respond func(protocol.Frame), _ net.Conn) {
+		received = append(received, f)
+		if len(received) < requests {
+			return
+		}
+		for i := len(received) - 1; i >= 0; i-- {
+			req := received[i]
+			respond(protocol.Frame{Kind: protocol.FrameResponse, Command: req.Command, RequestID: req.RequestID, Payload: req.Payload})
+		}
+	})
+
+	conn, err := Dial(context.Background(), addr)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			payload := fmt.Sprintf(`{"key":"key%d"}`, i)
+			resp, err := conn.Do(context.Background(), "get", []byte(payload))
+			assert.NoError(t, err)
+			assert.Equal(t, payload, string(resp), "every request should get its own response")
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConnErrors(t *testing.T) {
+	addr := fakeServer(t, func(f protocol.Frame, respond func(protocol.Frame), _ net.Conn) {
+		switch string(f.Payload) {
+		case "fail":
+			respond(protocol.Frame{Kind: protocol.FrameError, Command: f.Command, RequestID: f.RequestID,
+				Payload: []byte(`{"code":"collection_not_found","message":"no such collection"}`)})
+		case "event":
+			respond(protocol.Frame{Kind: protocol.FrameEvent, Command: f.Command, RequestID: f.RequestID, Payload: []byte("{}")})
+			respond(protocol.Frame{Kind: protocol.FrameResponse, Command: f.Command, RequestID: f.RequestID, Payload: []byte("ok")})
+		}
+		// Anything else never gets a response
+	})
+
+	conn, err := Dial(context.Background(), addr)
+	assert.NoError(t, err)
+
+	_, err = conn.Do(context.Background(), "nope", nil)
+	assert.Error(t, err, "unknown commands should fail without a request")
+
+	_, err = conn.Do(context.Background(), "get", []byte("fail"))
+	var serverErr *ServerError
+	assert.ErrorAs(t, err, &serverErr)
+	assert.Equal(t, "no such collection", serverErr.Message)
+	assert.ErrorIs(t, err, ErrCollectionNotFound, "the error code should map to the store error")
+
+	resp, err := conn.Do(context.Background(), "watch", []byte("event"))
+	assert.NoError(t, err, "the connection should stay usable after a server error")
+	assert.Equal(t, "ok", string(resp), "events should not be taken for responses")
+
+	done := make(chan error)
+	go func() {
+		_, err := conn.Do(context.Background(), "get", []byte("hang"))
+		done <- err
+	}()
+	// Wait until the request is registered so Close has something to fail
+	for {
+		conn.mx.Lock()
+		n := len(conn.pending)
+		conn.mx.Unlock()
+		if n > 0 {
+			break
+		}
+	}
+	conn.Close()
+	assert.ErrorIs(t, <-done, ErrClosed, "pending requests should fail when the connection closes")
+	_, err = conn.Do(context.Background(), "get", nil)
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestServerErrorsHaveCodes(t *testing.T) {
+	for _, err := range []error{
+		ErrMissingPrimaryKey, ErrPrimaryKeyNotString, ErrEmptyPrimaryKey, ErrDocumentNotFound,
+		ErrCollectionNotFound, ErrCollectionAlreadyExists, ErrIndexNotFound, ErrIndexAlreadyExists,
+		ErrInvalidIndex, ErrInvalidQuery, ErrUniqueConstraint, ErrInvalidFilter, ErrInvalidCursor,
+		ErrInvalidPageOptions, ErrInvalidSelect, ErrInvalidAggregation, ErrInvalidUpdate,
+		ErrRevisionConflict, ErrTxDone, ErrInvalidDocument, ErrRecordTooLarge,
+	} {
+		p := cmds.NewErrorPayload(err)
+		serverErr := &ServerError{Code: p.Code, Message: p.Message}
+		assert.ErrorIs(t, serverErr, err, "%v should survive the trip over the wire", err)
+	}
+}
@@ -0,0 +1,62 @@
+package client
+
+import store "hw12/internal/documentstore"
+
+// Documents, configs and errors are the store's own types. The store lives in
+// an internal package, so they are re-exported here for callers outside this
+// module.
+type (
+	Document          = store.Document
+	DocumentField     = store.DocumentField
+	DocumentFieldType = store.DocumentFieldType
+	CollectionConfig  = store.CollectionConfig
+	QueryParams       = store.QueryParams
+
+	UniqueConstraintError = store.UniqueConstraintError
+	RevisionConflictError = store.RevisionConflictError
+)
+
+const (
+	DocumentFieldTypeString = store.DocumentFieldTypeString
+	DocumentFieldTypeNumber = store.DocumentFieldTypeNumber
+	DocumentFieldTypeBool   = store.DocumentFieldTypeBool
+	DocumentFieldTypeArray  = store.DocumentFieldTypeArray
+	DocumentFieldTypeObject = store.DocumentFieldTypeObject
+)
+
+func StringValue(v string) *DocumentField {
+	return store.StringValue(v)
+}
+
+func NumberValue(v float64) *DocumentField {
+	return store.NumberValue(v)
+}
+
+func BoolValue(v bool) *DocumentField {
+	return store.BoolValue(v)
+}
+
+// Errors reported by the server, a ServerError unwraps to one of them.
+var (
+	ErrMissingPrimaryKey       = store.ErrMissingPrimaryKey
+	ErrPrimaryKeyNotString     = store.ErrPrimaryKeyNotString
+	ErrEmptyPrimaryKey         = store.ErrEmptyPrimaryKey
+	ErrDocumentNotFound        = store.ErrDocumentNotFound
+	ErrCollectionNotFound      = store.ErrCollectionNotFound
+	ErrCollectionAlreadyExists = store.ErrCollectionAlreadyExists
+	ErrIndexNotFound           = store.ErrIndexNotFound
+	ErrIndexAlreadyExists      = store.ErrIndexAlreadyExists
+	ErrInvalidIndex            = store.ErrInvalidIndex
+	ErrInvalidQuery            = store.ErrInvalidQuery
+	ErrUniqueConstraint        = store.ErrUniqueConstraint
+	ErrInvalidFilter           = store.ErrInvalidFilter
+	ErrInvalidCursor           = store.ErrInvalidCursor
+	ErrInvalidPageOptions      = store.ErrInvalidPageOptions
+	ErrInvalidSelect           = store.ErrInvalidSelect
+	ErrInvalidAggregation      = store.ErrInvalidAggregation
+	ErrInvalidUpdate           = store.ErrInvalidUpdate
+	ErrRevisionConflict        = store.ErrRevisionConflict
+	ErrTxDone                  = store.ErrTxDone
+	ErrInvalidDocument         = store.ErrInvalidDocument
+	ErrRecordTooLarge          = store.ErrRecordTooLarge
+)
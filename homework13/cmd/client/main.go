@@ -2,15 +2,20 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"strings"
+
+	cmds "hw12/internal/commands"
+	"hw12/internal/protocol"
 )
 
 func main() {
 	us := bufio.NewScanner(os.Stdin)
-	uw := bufio.NewWriter(os.Stdout)
 
 	conn, err := net.Dial("tcp", "localhost:9090")
 	if err != nil {
@@ -22,22 +27,72 @@ func main() {
 	sr := bufio.NewReader(conn)
 	sw := bufio.NewWriter(conn)
 
+	err = protocol.Handshake(sr, sw, protocol.FrameVersion)
+	if err != nil {
+		panic(fmt.Errorf("error switching to frames: %w", err))
+	}
+
+	// Watch events come at any time, so frames are read in the background
+	// and main only waits for the response to its last request
+	responses := make(chan struct{})
+	go func() {
+		for {
+			frame, err := protocol.ReadFrame(sr)
+			if err != nil {
+				if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+					fmt.Println(fmt.Errorf("error reading response: %w", err))
+				}
+				close(responses)
+				return
+			}
+			switch frame.Kind {
+			case protocol.FrameError:
+				p := &cmds.ErrorPayload{}
+				if json.Unmarshal(frame.Payload, p) != nil {
+					p.Message = string(frame.Payload)
+				}
+				fmt.Printf("error: %s\n", p.Message)
+			case protocol.FrameEvent:
+				fmt.Printf("event: %s\n", frame.Payload)
+				continue
+			default:
+				fmt.Printf("response: %s\n", frame.Payload)
+			}
+			responses <- struct{}{}
+		}
+	}()
+
+	var requestID uint32
 	for us.Scan() {
 		msg := strings.TrimSpace(us.Text())
-
-		// if
+		if msg == "" {
+			continue
+		}
 
 		fmt.Printf("User Input: %s\n", msg)
 
-		sw.WriteString(fmt.Sprintf("%s\n", msg))
-		sw.Flush()
-
-		resp, err := sr.ReadString('\n')
+		name, payload, _ := strings.Cut(msg, " ")
+		command, ok := protocol.CommandID(name)
+		if !ok {
+			fmt.Println("invalid command")
+			continue
+		}
+		requestID++
+		err = protocol.WriteFrame(sw, protocol.Frame{
+			Kind:      protocol.FrameRequest,
+			Command:   command,
+			RequestID: requestID,
+			Payload:   []byte(payload),
+		})
+		if err == nil {
+			err = sw.Flush()
+		}
 		if err != nil {
-			panic(fmt.Errorf("error reading response: %w", err))
+			panic(fmt.Errorf("error sending request: %w", err))
+		}
+		if _, ok := <-responses; !ok {
+			fmt.Println("connection closed")
+			return
 		}
-
-		uw.WriteString(resp)
-		uw.Flush()
 	}
-}
\ No newline at end of file
+}
@@ -1,170 +1,56 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
-	"strings"
+	"os"
+	"time"
 
-	cmds "hw12/internal/commands"
 	store "hw12/internal/documentstore"
+	"hw12/internal/server"
 )
 
-const primaryKey = "key"
-const collectionKey = "key"
-var s = store.NewStore()
+const defaultPrimaryKey = "key"
+const defaultDataDir = "data"
+const compactAfterMutations = 1000
+const compactInterval = time.Minute
+const reapInterval = time.Second
 
-func execPut(raw string, col *store.Collection) (string, error) {
-	p := &cmds.PutCommandRequestPayload{}
-	err := json.Unmarshal([]byte(raw), p)
+func main() {
+	l, err := net.Listen("tcp", "0.0.0.0:9090")
 	if err != nil {
-		return "", fmt.Errorf("error unmarshalling payload: %w", err)
+		panic(fmt.Errorf("error listening: %w", err))
 	}
-	d1 := store.Document{Fields: make(map[string]store.DocumentField)}
-	d1.Fields[primaryKey] = store.DocumentField{Type: store.DocumentFieldTypeString, Value: p.Key}
-	d1.Fields["val"] = store.DocumentField{Type: store.DocumentFieldTypeString, Value: p.Value}
-	col.Put(d1)
 
-	resp := &cmds.PutCommandResponsePayload{}
-	rawResp, err := json.Marshal(resp)
-	if err != nil {
-		return "", fmt.Errorf("error marshalling response: %w", err)
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = defaultDataDir
 	}
-
-	fmt.Println(rawResp)
-	return string(rawResp), nil
-}
-
-func execGet(raw string, col *store.Collection) (string, error) {
-	p := &cmds.GetCommandRequestPayload{}
-	err := json.Unmarshal([]byte(raw), p)
+	s, err := store.OpenStore(dataDir)
 	if err != nil {
-		return "", fmt.Errorf("error unmarshalling payload: %w", err)
+		panic(fmt.Errorf("error opening store: %w", err))
 	}
-
-	doc, ok := col.Get(p.Key)
-	var value string
-	if ok {
-		value = doc.Fields["val"].Value.(string)
-	}
-
-	resp := &cmds.GetCommandResponsePayload{
-		Value: value,
-		Ok:    ok,
-	}
-
-	rawResp, err := json.Marshal(resp)
+	defer s.Close()
+	err = s.StartCompaction(store.CompactionConfig{MaxMutations: compactAfterMutations, Interval: compactInterval})
 	if err != nil {
-		return "", fmt.Errorf("error marshalling response: %w", err)
+		panic(fmt.Errorf("error starting compaction: %w", err))
 	}
-
-	return string(rawResp), nil
-}
-
-func execDelete(raw string, col *store.Collection) (string, error) {
-	p := &cmds.DeleteCommandRequestPayload{}
-	err := json.Unmarshal([]byte(raw), p)
+	err = s.StartReaper(reapInterval)
 	if err != nil {
-		return "", fmt.Errorf("error unmarshalling payload: %w", err)
+		panic(fmt.Errorf("error starting reaper: %w", err))
 	}
 
-	ok := col.Delete(p.Key)
-	resp := &cmds.DeleteCommandResponsePayload{
-		Ok: ok,
-	}
-
-	rawResp, err := json.Marshal(resp)
-	if err != nil {
-		return "", fmt.Errorf("error marshalling response: %w", err)
-	}
-
-	return string(rawResp), nil
-}
-
-func execList(col *store.Collection) (string, error) {
-	documents := col.List()
-	values := make([]string, len(documents))
-
-	for i, doc := range documents {
-		values[i] = doc.Fields["val"].Value.(string)
-	}
-
-	resp := &cmds.ListCommandResponsePayload{
-		Value: values,
-		Ok:    true,
-	}
-
-	rawResp, err := json.Marshal(resp)
-	if err != nil {
-		return "", fmt.Errorf("error marshalling response: %w", err)
-	}
-
-	return string(rawResp), nil
-}
-
-func handleConnection(conn net.Conn, key string) {
-	defer conn.Close()
-
-	scanner := bufio.NewScanner(conn)
-	w := bufio.NewWriter(conn)
-
-	for scanner.Scan() {
-		msg := scanner.Text()
-
-		elems := strings.Split(msg, " ")
-		length := len(elems)
-		if length != 1 && length != 2 {
-			w.WriteString("invalid command\n")
-			w.Flush()
-			continue
-		}
-
-		col, found := s.GetCollection(key)
-		if !found {
-			panic("Collection not found")
-		}
-		var resp string
-		var err error
-
-		switch elems[0] {
-		case cmds.PutCommandName:
-			resp, err = execPut(elems[1], col)
-		case cmds.GetCommandName:
-			resp, err = execGet(elems[1], col)
-		case cmds.DeleteCommandName:
-			resp, err = execDelete(elems[1], col)
-		case cmds.ListCommandName:
-			resp, err = execList(col)
-		default:
-			w.WriteString("invalid command\n")
-		}
-
+	if _, err := s.GetCollection(server.DefaultCollection); errors.Is(err, store.ErrCollectionNotFound) {
+		cfg := store.CollectionConfig{PrimaryKey: defaultPrimaryKey}
+		_, err = s.CreateCollection(server.DefaultCollection, &cfg)
 		if err != nil {
-			w.WriteString(fmt.Sprintf("error: %s\n", err))
+			fmt.Println(fmt.Errorf("collection creation failed: %w", err))
+			return
 		}
-
-		w.WriteString(fmt.Sprintf("response: %s\n", resp))
-
-		w.Flush()
-	}
-
-	fmt.Println("connection closed")
-}
-
-func main() {
-	l, err := net.Listen("tcp", "0.0.0.0:9090")
-	if err != nil {
-		panic(fmt.Errorf("error listening: %w", err))
-	}
-
-	cfg := store.CollectionConfig{PrimaryKey: primaryKey}
-	ok, _ := s.CreateCollection(collectionKey, &cfg)
-	if !ok {
-		fmt.Println("Collection creation failed")
-		return
 	}
 
+	srv := server.New(s)
 	for {
 		conn, err := l.Accept()
 		if err != nil {
@@ -174,6 +60,6 @@ func main() {
 
 		fmt.Println("connection accepted")
 
-		go handleConnection(conn, collectionKey)
+		go srv.HandleConnection(conn)
 	}
 }
\ No newline at end of file
@@ -2,7 +2,10 @@ module hw12
 
 go 1.24.1
 
-require github.com/stretchr/testify v1.10.0
+require (
+	github.com/google/btree v1.1.3
+	github.com/stretchr/testify v1.10.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -1,39 +1,242 @@
 package commands
 
+import (
+	"encoding/json"
+
+	store "hw12/internal/documentstore"
+)
+
+// Document commands work on the collection named in their payload, or on the
+// server's default collection when it is omitted.
+
+// Documents go over the wire in the store's own JSON form, e.g.
+// {"Fields":{"id":{"Type":"string","Value":"u1"}}}, so field types are kept.
+// The document has to contain the collection's primary key field.
+//
+// Revision makes `put` and `delete` conditional: they fail unless the stored
+// document has this revision. For `put` 0 means the key must not exist yet.
 type PutCommandRequestPayload struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+	Collection string         `json:"collection,omitempty"`
+	Document   store.Document `json:"document"`
+	Revision   *uint64        `json:"revision,omitempty"`
 }
 
-type PutCommandResponsePayload struct{}
+type PutCommandResponsePayload struct {
+	Revision uint64 `json:"revision,omitempty"` // Not set inside a transaction, it's only known on commit
+}
 
 type GetCommandRequestPayload struct {
-	Key string `json:"key"`
+	Collection string `json:"collection,omitempty"`
+	Key        string `json:"key"`
 }
 
 type GetCommandResponsePayload struct {
-	Value string `json:"value"`
-	Ok    bool   `json:"ok"`
+	Document *store.Document `json:"document,omitempty"`
+	Ok       bool            `json:"ok"`
+	Revision uint64          `json:"revision,omitempty"`
 }
 
 type DeleteCommandRequestPayload struct {
-	Key string `json:"key"`
+	Collection string  `json:"collection,omitempty"`
+	Key        string  `json:"key"`
+	Revision   *uint64 `json:"revision,omitempty"`
 }
 
 type DeleteCommandResponsePayload struct {
 	Ok bool `json:"ok"`
 }
 
-type ListCommandRequestPayload struct {}
+type ListCommandRequestPayload struct {
+	Collection string `json:"collection,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+	Cursor     string `json:"cursor,omitempty"`
+}
 
 type ListCommandResponsePayload struct {
+	Value      []store.Document `json:"value"`
+	Ok         bool             `json:"ok"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type SortField struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc,omitempty"`
+}
+
+type SelectCommandRequestPayload struct {
+	Collection string      `json:"collection,omitempty"`
+	Fields     []string    `json:"fields,omitempty"`
+	Sort       []SortField `json:"sort,omitempty"`
+	Limit      int         `json:"limit,omitempty"`
+	Offset     int         `json:"offset,omitempty"`
+}
+
+type SelectCommandResponsePayload struct {
+	Value []store.Document `json:"value"`
+	Ok    bool             `json:"ok"`
+}
+
+type Aggregation struct {
+	Op    string `json:"op"`
+	Field string `json:"field,omitempty"`
+	As    string `json:"as,omitempty"`
+}
+
+type AggregateCommandRequestPayload struct {
+	Collection   string        `json:"collection,omitempty"`
+	GroupBy      []string      `json:"group_by,omitempty"`
+	Aggregations []Aggregation `json:"aggregations"`
+}
+
+type AggregateGroup struct {
+	Key    map[string]store.DocumentField `json:"key"`
+	Values map[string]float64             `json:"values"`
+}
+
+type AggregateCommandResponsePayload struct {
+	Value []AggregateGroup `json:"value"`
+	Ok    bool             `json:"ok"`
+}
+
+type UpdateOp struct {
+	Op    string      `json:"op"`
+	Field string      `json:"field"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+type UpdateCommandRequestPayload struct {
+	Collection string     `json:"collection,omitempty"`
+	Key        string     `json:"key"`
+	Ops        []UpdateOp `json:"ops"`
+}
+
+type UpdateCommandResponsePayload struct {
+	Ok       bool   `json:"ok"`
+	Revision uint64 `json:"revision,omitempty"`
+}
+
+// Response of `begin`, `commit` and `rollback`. While a transaction is open
+// on the connection `put`, `get` and `delete` go through it, `list`, `select`
+// and `aggregate` only see committed documents and `update` is rejected.
+type TxCommandResponsePayload struct {
+	Ok bool `json:"ok"`
+}
+
+// After a successful `watch` the server sends a WatchEventPayload for every
+// change until `unwatch`, as an `event:` line or as an event frame carrying
+// the request id of the `watch`.
+type WatchCommandRequestPayload struct {
+	Collection string          `json:"collection,omitempty"`
+	Filter     json.RawMessage `json:"filter,omitempty"` // Same JSON as documentstore.Filter
+	Buffer     int             `json:"buffer,omitempty"`
+}
+
+type WatchCommandResponsePayload struct {
+	Ok bool `json:"ok"`
+}
+
+// WatchEventPayload with only Error set is the last event of a watch that
+// was stopped by the server, e.g. because the client didn't keep up.
+type WatchEventPayload struct {
+	Type     string          `json:"type,omitempty"`
+	Key      string          `json:"key,omitempty"`
+	Revision uint64          `json:"revision,omitempty"`
+	Before   *store.Document `json:"before,omitempty"`
+	After    *store.Document `json:"after,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// TTL is a Go duration string such as "90s" or "24h".
+type CreateCollectionCommandRequestPayload struct {
+	Name       string `json:"name"`
+	PrimaryKey string `json:"primary_key"`
+	TTL        string `json:"ttl,omitempty"`
+}
+
+type CreateCollectionCommandResponsePayload struct {
+	Ok bool `json:"ok"`
+}
+
+type DropCollectionCommandRequestPayload struct {
+	Name string `json:"name"`
+}
+
+type DropCollectionCommandResponsePayload struct {
+	Ok bool `json:"ok"`
+}
+
+type ListCollectionsCommandResponsePayload struct {
 	Value []string `json:"value"`
-	Ok    bool   `json:"ok"`
+	Ok    bool     `json:"ok"`
+}
+
+type DescribeCollectionCommandRequestPayload struct {
+	Name string `json:"name"`
+}
+
+type DescribeCollectionCommandResponsePayload struct {
+	Name          string   `json:"name"`
+	PrimaryKey    string   `json:"primary_key"`
+	TTL           string   `json:"ttl,omitempty"`
+	Documents     int      `json:"documents"`
+	Indexes       []string `json:"indexes,omitempty"`
+	UniqueIndexes []string `json:"unique_indexes,omitempty"`
+	Ok            bool     `json:"ok"`
+}
+
+// Field of a compound index is its fields joined with a comma, as in
+// documentstore.Collection.Query.
+type QueryCommandRequestPayload struct {
+	Collection string            `json:"collection,omitempty"`
+	Field      string            `json:"field"`
+	Params     store.QueryParams `json:"params"`
+}
+
+type QueryCommandResponsePayload struct {
+	Value []store.Document `json:"value"`
+	Ok    bool             `json:"ok"`
+}
+
+// Several fields make a compound index.
+type CreateIndexCommandRequestPayload struct {
+	Collection string   `json:"collection,omitempty"`
+	Fields     []string `json:"fields"`
+	Unique     bool     `json:"unique,omitempty"`
+}
+
+type CreateIndexCommandResponsePayload struct {
+	Ok bool `json:"ok"`
+}
+
+type DropIndexCommandRequestPayload struct {
+	Collection string   `json:"collection,omitempty"`
+	Fields     []string `json:"fields"`
+}
+
+type DropIndexCommandResponsePayload struct {
+	Ok bool `json:"ok"`
 }
 
 const (
-	PutCommandName    string = "put"
-	GetCommandName    string = "get"
-	DeleteCommandName string = "delete"
-	ListCommandName string = "list"
+	PutCommandName       string = "put"
+	GetCommandName       string = "get"
+	DeleteCommandName    string = "delete"
+	ListCommandName      string = "list"
+	SelectCommandName    string = "select"
+	AggregateCommandName string = "aggregate"
+	UpdateCommandName    string = "update"
+	BeginCommandName     string = "begin"
+	CommitCommandName    string = "commit"
+	RollbackCommandName  string = "rollback"
+	WatchCommandName     string = "watch"
+	UnwatchCommandName   string = "unwatch"
+
+	CreateCollectionCommandName   string = "create_collection"
+	DropCollectionCommandName     string = "drop_collection"
+	ListCollectionsCommandName    string = "list_collections"
+	DescribeCollectionCommandName string = "describe_collection"
+
+	QueryCommandName       string = "query"
+	CreateIndexCommandName string = "create_index"
+	DropIndexCommandName   string = "drop_index"
 )
@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"errors"
+
+	store "hw12/internal/documentstore"
+)
+
+// ErrorPayload is the payload of error frames. Code names the store error
+// behind the failure, so clients don't have to match Message.
+type ErrorPayload struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// Clients rely on these codes, existing ones must not change.
+var errorCodes = []struct {
+	code string
+	err  error
+}{
+	{"missing_primary_key", store.ErrMissingPrimaryKey},
+	{"primary_key_not_string", store.ErrPrimaryKeyNotString},
+	{"empty_primary_key", store.ErrEmptyPrimaryKey},
+	{"document_not_found", store.ErrDocumentNotFound},
+	{"collection_not_found", store.ErrCollectionNotFound},
+	{"collection_already_exists", store.ErrCollectionAlreadyExists},
+	{"index_not_found", store.ErrIndexNotFound},
+	{"index_already_exists", store.ErrIndexAlreadyExists},
+	{"invalid_index", store.ErrInvalidIndex},
+	{"invalid_query", store.ErrInvalidQuery},
+	{"unique_constraint", store.ErrUniqueConstraint},
+	{"invalid_filter", store.ErrInvalidFilter},
+	{"invalid_cursor", store.ErrInvalidCursor},
+	{"invalid_page_options", store.ErrInvalidPageOptions},
+	{"invalid_select", store.ErrInvalidSelect},
+	{"invalid_aggregation", store.ErrInvalidAggregation},
+	{"invalid_update", store.ErrInvalidUpdate},
+	{"revision_conflict", store.ErrRevisionConflict},
+	{"tx_done", store.ErrTxDone},
+	{"invalid_document", store.ErrInvalidDocument},
+	{"record_too_large", store.ErrRecordTooLarge},
+}
+
+// NewErrorPayload sets Code if err wraps one of the store errors.
+func NewErrorPayload(err error) *ErrorPayload {
+	p := &ErrorPayload{Message: err.Error()}
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			p.Code = c.code
+			break
+		}
+	}
+	return p
+}
+
+// Err returns the store error of the code, nil if there is none.
+func (p *ErrorPayload) Err() error {
+	for _, c := range errorCodes {
+		if c.code == p.Code {
+			return c.err
+		}
+	}
+	return nil
+}
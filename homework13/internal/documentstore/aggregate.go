@@ -0,0 +1,208 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+type AggregateOp string
+
+const (
+	AggregateOpCount AggregateOp = "count"
+	AggregateOpSum   AggregateOp = "sum"
+	AggregateOpMin   AggregateOp = "min"
+	AggregateOpMax   AggregateOp = "max"
+	AggregateOpAvg   AggregateOp = "avg"
+)
+
+// Aggregation computes one value per group. Count without a path counts
+// documents, with a path only the ones having that field. The other
+// operators only take DocumentFieldTypeNumber values into account.
+type Aggregation struct {
+	Op   AggregateOp `json:"op"`
+	Path string      `json:"path,omitempty"`
+	As   string      `json:"as,omitempty"` // Name of the result, e.g. `sum(age)` by default
+}
+
+type AggregateOptions struct {
+	Filter       *Filter  // Nil matches every document
+	GroupBy      []string // Paths to group by, empty puts all documents in one group
+	Aggregations []Aggregation
+}
+
+type AggregateGroup struct {
+	Key    map[string]DocumentField // Values of the GroupBy paths, missing ones are left out
+	Values map[string]float64       // Min, max and avg are left out if the group has no numbers
+}
+
+func (a Aggregation) name() string {
+	if a.As != "" {
+		return a.As
+	}
+	if a.Path == "" {
+		return string(a.Op)
+	}
+	return fmt.Sprintf("%s(%s)", a.Op, a.Path)
+}
+
+func (o AggregateOptions) validate() error {
+	if len(o.Aggregations) == 0 {
+		return fmt.Errorf("%w: no aggregations", ErrInvalidAggregation)
+	}
+	for _, path := range o.GroupBy {
+		if path == "" {
+			return fmt.Errorf("%w: empty group by path", ErrInvalidAggregation)
+		}
+	}
+	names := make(map[string]struct{}, len(o.Aggregations))
+	for _, a := range o.Aggregations {
+		switch a.Op {
+		case AggregateOpCount:
+		case AggregateOpSum, AggregateOpMin, AggregateOpMax, AggregateOpAvg:
+			if a.Path == "" {
+				return fmt.Errorf("%w: %s needs a path", ErrInvalidAggregation, a.Op)
+			}
+		default:
+			return fmt.Errorf("%w: unknown operator %q", ErrInvalidAggregation, a.Op)
+		}
+		if _, dup := names[a.name()]; dup {
+			return fmt.Errorf("%w: duplicate result name %q", ErrInvalidAggregation, a.name())
+		}
+		names[a.name()] = struct{}{}
+	}
+	return nil
+}
+
+// accumulator holds the running state of one aggregation in one group.
+type accumulator struct {
+	count    int
+	sum      float64
+	min, max float64
+}
+
+func (acc *accumulator) add(value float64) {
+	if acc.count == 0 || value < acc.min {
+		acc.min = value
+	}
+	if acc.count == 0 || value > acc.max {
+		acc.max = value
+	}
+	acc.count++
+	acc.sum += value
+}
+
+func (acc *accumulator) result(op AggregateOp) (float64, bool) {
+	switch op {
+	case AggregateOpCount:
+		return float64(acc.count), true
+	case AggregateOpSum:
+		return acc.sum, true
+	}
+	if acc.count == 0 {
+		return 0, false
+	}
+	switch op {
+	case AggregateOpMin:
+		return acc.min, true
+	case AggregateOpMax:
+		return acc.max, true
+	}
+	return acc.sum / float64(acc.count), true
+}
+
+type aggregateGroup struct {
+	id    string
+	key   []DocumentField
+	found []bool
+	accs  []accumulator
+}
+
+// Aggregate groups the matching documents and computes the aggregations for
+// each group. Groups are ordered by their key the same way `Select` sorts.
+func (s *Collection) Aggregate(opts AggregateOptions) ([]AggregateGroup, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	var docs []Document
+	var err error
+	if opts.Filter != nil {
+		docs, err = s.Find(*opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		docs = s.List()
+	}
+
+	groups := make(map[string]*aggregateGroup)
+	for _, doc := range docs {
+		g := &aggregateGroup{key: make([]DocumentField, len(opts.GroupBy)), found: make([]bool, len(opts.GroupBy))}
+		for i, path := range opts.GroupBy {
+			g.key[i], g.found[i] = resolvePath(doc, path)
+		}
+		id, err := json.Marshal([]interface{}{g.key, g.found})
+		if err != nil {
+			return nil, fmt.Errorf("%w: can't group by value: %s", ErrInvalidAggregation, err)
+		}
+		if existing, ok := groups[string(id)]; ok {
+			g = existing
+		} else {
+			g.id = string(id)
+			g.accs = make([]accumulator, len(opts.Aggregations))
+			groups[string(id)] = g
+		}
+
+		for i, a := range opts.Aggregations {
+			if a.Op == AggregateOpCount && a.Path == "" {
+				g.accs[i].count++
+				continue
+			}
+			field, found := resolvePath(doc, a.Path)
+			if !found {
+				continue
+			}
+			if a.Op == AggregateOpCount {
+				g.accs[i].count++
+				continue
+			}
+			if field.Type != DocumentFieldTypeNumber {
+				continue
+			}
+			if value, ok := toFloat64(field.Value); ok {
+				g.accs[i].add(value)
+			}
+		}
+	}
+
+	sorted := make([]*aggregateGroup, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		for k := range opts.GroupBy {
+			c := compareSortValues(sorted[i].key[k], sorted[i].found[k], sorted[j].key[k], sorted[j].found[k])
+			if c != 0 {
+				return c < 0
+			}
+		}
+		// Keys that can't be ordered, e.g. objects, still need a stable order
+		return sorted[i].id < sorted[j].id
+	})
+
+	result := make([]AggregateGroup, len(sorted))
+	for i, g := range sorted {
+		result[i] = AggregateGroup{Key: make(map[string]DocumentField), Values: make(map[string]float64)}
+		for k, path := range opts.GroupBy {
+			if g.found[k] {
+				result[i].Key[path] = g.key[k]
+			}
+		}
+		for k, a := range opts.Aggregations {
+			if value, ok := g.accs[k].result(a.Op); ok {
+				result[i].Values[a.name()] = value
+			}
+		}
+	}
+	return result, nil
+}
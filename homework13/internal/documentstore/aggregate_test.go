@@ -0,0 +1,76 @@
+package documentstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregate(t *testing.T) {
+	col := newPeopleCollection(t)
+
+	groups, err := col.Aggregate(AggregateOptions{
+		GroupBy: []string{"address.city"},
+		Aggregations: []Aggregation{
+			{Op: AggregateOpCount},
+			{Op: AggregateOpSum, Path: "age"},
+			{Op: AggregateOpMin, Path: "age"},
+			{Op: AggregateOpMax, Path: "age"},
+			{Op: AggregateOpAvg, Path: "age", As: "avg_age"},
+		},
+	})
+	assert.NoError(t, err, "Aggregate should not return an error")
+	assert.Equal(t, []AggregateGroup{
+		{Key: map[string]DocumentField{}, Values: map[string]float64{"count": 1, "sum(age)": 0}},
+		{
+			Key:    map[string]DocumentField{"address.city": {Type: DocumentFieldTypeString, Value: "Kyiv"}},
+			Values: map[string]float64{"count": 2, "sum(age)": 71, "min(age)": 30, "max(age)": 41, "avg_age": 35.5},
+		},
+		{
+			Key:    map[string]DocumentField{"address.city": {Type: DocumentFieldTypeString, Value: "Lviv"}},
+			Values: map[string]float64{"count": 1, "sum(age)": 25, "min(age)": 25, "max(age)": 25, "avg_age": 25},
+		},
+		{
+			Key:    map[string]DocumentField{"address.city": {Type: DocumentFieldTypeString, Value: "Odesa"}},
+			Values: map[string]float64{"count": 1, "sum(age)": 17, "min(age)": 17, "max(age)": 17, "avg_age": 17},
+		},
+	}, groups, "documents missing the group field should form their own group")
+}
+
+func TestAggregateMultipleKeysWithFilter(t *testing.T) {
+	col := newPeopleCollection(t)
+
+	filter := Exists("name")
+	groups, err := col.Aggregate(AggregateOptions{
+		Filter:       &filter,
+		GroupBy:      []string{"active", "address.city"},
+		Aggregations: []Aggregation{{Op: AggregateOpCount, Path: "age"}},
+	})
+	assert.NoError(t, err)
+	keys := make([][2]interface{}, len(groups))
+	for i, g := range groups {
+		keys[i] = [2]interface{}{g.Key["active"].Value, g.Key["address.city"].Value}
+	}
+	assert.Equal(t, [][2]interface{}{{false, "Lviv"}, {true, "Kyiv"}, {true, "Odesa"}}, keys)
+	assert.Equal(t, float64(2), groups[1].Values["count(age)"])
+
+	groups, err = col.Aggregate(AggregateOptions{Aggregations: []Aggregation{{Op: AggregateOpCount}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []AggregateGroup{{Key: map[string]DocumentField{}, Values: map[string]float64{"count": 5}}}, groups,
+		"without group by every document should be in one group")
+}
+
+func TestAggregateInvalidOptions(t *testing.T) {
+	col := newPeopleCollection(t)
+
+	for _, opts := range []AggregateOptions{
+		{},
+		{Aggregations: []Aggregation{{Op: "median", Path: "age"}}},
+		{Aggregations: []Aggregation{{Op: AggregateOpSum}}},
+		{Aggregations: []Aggregation{{Op: AggregateOpCount}, {Op: AggregateOpSum, Path: "age", As: "count"}}},
+		{GroupBy: []string{""}, Aggregations: []Aggregation{{Op: AggregateOpCount}}},
+	} {
+		_, err := col.Aggregate(opts)
+		assert.ErrorIs(t, err, ErrInvalidAggregation, "options %+v should be rejected", opts)
+	}
+}
@@ -2,27 +2,46 @@ package documentstore
 
 import (
 	"encoding/json"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 )
 
 type Collection struct {
 	docs map[string]Document
 	config CollectionConfig
 	mx sync.RWMutex
+	index map[string]*CollectionIndex
+	name string
+	wal *wal
+	revision uint64 // Last revision assigned in this collection
+	dropped bool // Set once the collection is removed from its store
+	watchers map[*Watcher]struct{}
 }
 
 type CollectionConfig struct {
 	PrimaryKey string
+	// TTL expires documents this long after their last write, 0 keeps them forever
+	TTL time.Duration `json:",omitempty"`
 }
 
-func (s Collection) MarshalJSON() ([]byte, error) {
+func (s *Collection) MarshalJSON() ([]byte, error) {
 	type Alias struct {
 		Docs map[string]Document `json:"docs"`
 		Config CollectionConfig `json:"config"`
+		Indexes []string `json:"indexes,omitempty"`
+		UniqueIndexes []string `json:"unique_indexes,omitempty"`
+		Revision uint64 `json:"revision,omitempty"`
 	}
+	s.mx.RLock()
+	defer s.mx.RUnlock()
 	alias := Alias{
 		Docs: s.docs,
 		Config: s.config,
+		Indexes: s.indexNames(false),
+		UniqueIndexes: s.indexNames(true),
+		Revision: s.revision,
 	}
 
 	return json.Marshal(alias)
@@ -33,6 +52,9 @@ func (s *Collection) UnmarshalJSON(data []byte) error {
 	alias := struct {
 		Docs map[string]Document `json:"docs"`
 		Config CollectionConfig `json:"config"`
+		Indexes []string `json:"indexes"`
+		UniqueIndexes []string `json:"unique_indexes"`
+		Revision uint64 `json:"revision"`
 	}{}
 
 	// Unmarshal into the alias
@@ -44,60 +66,277 @@ func (s *Collection) UnmarshalJSON(data []byte) error {
 	s.docs = alias.Docs
 	s.config = alias.Config
 	s.mx = sync.RWMutex{}
+	s.index = make(map[string]*CollectionIndex)
+	s.revision = alias.Revision
+	for _, doc := range s.docs {
+		if doc.Revision > s.revision {
+			s.revision = doc.Revision
+		}
+	}
+	// Dumps made before revisions existed get them in key order
+	for _, key := range s.sortedKeys() {
+		if doc := s.docs[key]; doc.Revision == 0 {
+			doc.Revision = s.nextRevision()
+			s.docs[key] = doc
+		}
+	}
+	// Only index names are dumped, the trees are rebuilt from the documents
+	for _, fieldName := range alias.Indexes {
+		s.index[fieldName], _ = s.buildIndex(indexFields(fieldName), false)
+	}
+	for _, fieldName := range alias.UniqueIndexes {
+		idx, err := s.buildIndex(indexFields(fieldName), true)
+		if err != nil {
+			return err
+		}
+		s.index[fieldName] = idx
+	}
 	return nil
 }
 
 
-func (s *Collection) Put(doc Document) {
-	// Потрібно перевірити що документ містить поле `{cfg.PrimaryKey}` типу `string`
-	keyField, ok := doc.Fields[s.config.PrimaryKey]
-	if !ok {
-		return
+// Put returns the revision assigned to the document. It returns
+// ErrMissingPrimaryKey, ErrPrimaryKeyNotString or ErrEmptyPrimaryKey for
+// documents without a valid primary key, and a *UniqueConstraintError if a
+// unique index already has the document's value for another primary key.
+func (s *Collection) Put(doc Document) (uint64, error) {
+	return s.put(doc, nil)
+}
+
+// PutIf stores the document only if the current one has the given revision,
+// 0 means there must be no document with this key yet. It returns the new
+// revision, or a *RevisionConflictError if the revision doesn't match.
+func (s *Collection) PutIf(doc Document, revision uint64) (uint64, error) {
+	return s.put(doc, &revision)
+}
+
+func (s *Collection) put(doc Document, expected *uint64) (uint64, error) {
+	key, err := s.primaryKey(doc)
+	if err != nil {
+		return 0, err
 	}
 	s.mx.Lock()
 	defer func() {
 		s.mx.Unlock()
 	}()
-	if keyField.Type != DocumentFieldTypeString {
+	if err := s.checkDropped(); err != nil {
+		return 0, err
+	}
+	if err := s.dropExpired(key); err != nil {
+		return 0, err
+	}
+	old, had := s.docs[key]
+	if err := checkRevision(key, old, had, expected); err != nil {
+		return 0, err
+	}
+	if err := s.checkUniqueIndexes(key, doc); err != nil {
+		return 0, err
+	}
+	// The caller may keep changing its document after the put
+	doc = doc.Clone()
+	s.applyTTL(&doc)
+	doc.Revision = s.nextRevision()
+	if s.wal != nil {
+		err := s.wal.append(walRecord{Op: walOpPut, Collection: s.name, Key: key, Doc: &doc})
+		if err != nil {
+			logger.Error("Failed to log put", "collection", s.name, "key", key, "error", err)
+			return 0, err
+		}
+	}
+	s.docs[key] = doc
+	if had {
+		s.updateIndex(key, &old, &doc)
+		s.notify(key, &old, &doc)
+	} else {
+		s.updateIndex(key, nil, &doc)
+		s.notify(key, nil, &doc)
+	}
+	return doc.Revision, nil
+}
+
+// nextRevision has to be called with the collection's lock held. Failed
+// writes leave gaps, revisions only have to increase.
+func (s *Collection) nextRevision() uint64 {
+	s.revision++
+	return s.revision
+}
+
+// checkDropped has to be called with the collection's lock held.
+func (s *Collection) checkDropped() error {
+	if s.dropped {
+		return fmt.Errorf("%w: %s", ErrCollectionNotFound, s.name)
+	}
+	return nil
+}
+
+func checkRevision(key string, doc Document, found bool, expected *uint64) error {
+	if expected == nil {
+		return nil
+	}
+	var actual uint64
+	if found {
+		actual = doc.Revision
+	}
+	if actual != *expected {
+		return &RevisionConflictError{Key: key, Expected: *expected, Actual: actual}
+	}
+	return nil
+}
+
+// restore stores a document read from the wal keeping its revision.
+func (s *Collection) restore(doc Document) {
+	key, err := s.primaryKey(doc)
+	if err != nil {
 		return
 	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if doc.Revision == 0 {
+		// Logged before revisions existed
+		doc.Revision = s.nextRevision()
+	}
+	if doc.Revision > s.revision {
+		s.revision = doc.Revision
+	}
+	old, had := s.docs[key]
+	s.docs[key] = doc
+	if had {
+		s.updateIndex(key, &old, &doc)
+	} else {
+		s.updateIndex(key, nil, &doc)
+	}
+}
+
+// Потрібно перевірити що документ містить поле `{cfg.PrimaryKey}` типу `string`
+func (s *Collection) primaryKey(doc Document) (string, error) {
+	keyField, ok := doc.Fields[s.config.PrimaryKey]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrMissingPrimaryKey, s.config.PrimaryKey)
+	}
+	if keyField.Type != DocumentFieldTypeString {
+		return "", fmt.Errorf("%w: %s has type %s", ErrPrimaryKeyNotString, s.config.PrimaryKey, keyField.Type)
+	}
 	key, isString := keyField.Value.(string)
-	if isString && len(key) > 0 {
-		s.docs[key] = doc
+	if !isString {
+		return "", fmt.Errorf("%w: %s has value %v", ErrPrimaryKeyNotString, s.config.PrimaryKey, keyField.Value)
+	}
+	if len(key) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrEmptyPrimaryKey, s.config.PrimaryKey)
 	}
+	return key, nil
 }
 
-func (s *Collection) Get(key string) (*Document, bool) {
+// Get returns ErrDocumentNotFound if there is no document with this key.
+func (s *Collection) Get(key string) (*Document, error) {
 	s.mx.RLock()
 	defer func() {
 		s.mx.RUnlock()
 	}()
-	doc, ok := s.docs[key]
-	return &doc, ok
+	doc, ok := s.liveDoc(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDocumentNotFound, key)
+	}
+	doc = doc.Clone()
+	return &doc, nil
+}
+
+// Delete returns ErrDocumentNotFound if there is no document with this key.
+func (s *Collection) Delete(key string) error {
+	return s.delete(key, nil)
+}
+
+// DeleteIf removes the document only if it has the given revision, otherwise
+// it returns a *RevisionConflictError.
+func (s *Collection) DeleteIf(key string, revision uint64) error {
+	return s.delete(key, &revision)
 }
 
-func (s *Collection) Delete(key string) bool {
+func (s *Collection) delete(key string, expected *uint64) error {
 	s.mx.Lock()
 	defer func() {
 		s.mx.Unlock()
 	}()
-	_, ok := s.docs[key]
+	if err := s.checkDropped(); err != nil {
+		return err
+	}
+	if err := s.dropExpired(key); err != nil {
+		return err
+	}
+	doc, ok := s.docs[key]
 	if !ok {
-		return false
+		return fmt.Errorf("%w: %s", ErrDocumentNotFound, key)
+	}
+	if err := checkRevision(key, doc, ok, expected); err != nil {
+		return err
+	}
+	if s.wal != nil {
+		err := s.wal.append(walRecord{Op: walOpDelete, Collection: s.name, Key: key})
+		if err != nil {
+			logger.Error("Failed to log delete", "collection", s.name, "key", key, "error", err)
+			return err
+		}
 	}
 	delete(s.docs, key)
-	return true
+	s.updateIndex(key, &doc, nil)
+	s.notify(key, &doc, nil)
+	return nil
 }
 
+// List returns copies of all documents ordered by primary key.
 func (s *Collection) List() []Document {
-	values := make([]Document, 0, len(s.docs))
 	s.mx.RLock()
 	defer func() {
 		s.mx.RUnlock()
 	}()
-	for _, doc := range s.docs {
-		values = append(values, doc)
+	keys := s.sortedKeys()
+	values := make([]Document, 0, len(keys))
+	for _, key := range keys {
+		if doc, ok := s.liveDoc(key); ok {
+			values = append(values, doc.Clone())
+		}
 	}
 
 	return values
+}
+
+// Config returns the config the collection was created with.
+func (s *Collection) Config() CollectionConfig {
+	return s.config
+}
+
+// CollectionInfo describes a collection without its documents.
+type CollectionInfo struct {
+	Name          string
+	Config        CollectionConfig
+	Documents     int      // Not counting expired documents the reaper hasn't removed yet
+	Indexes       []string // Index names, compound ones joined with ","
+	UniqueIndexes []string
+}
+
+// Info returns the collection's name, config, size and indexes.
+func (s *Collection) Info() CollectionInfo {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	info := CollectionInfo{
+		Name:          s.name,
+		Config:        s.config,
+		Indexes:       s.indexNames(false),
+		UniqueIndexes: s.indexNames(true),
+	}
+	for key := range s.docs {
+		if _, ok := s.liveDoc(key); ok {
+			info.Documents++
+		}
+	}
+	return info
+}
+
+// sortedKeys has to be called with the collection's lock held.
+func (s *Collection) sortedKeys() []string {
+	keys := make([]string, 0, len(s.docs))
+	for key := range s.docs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
\ No newline at end of file
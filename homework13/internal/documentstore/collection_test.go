@@ -19,7 +19,7 @@ func TestMarshalJSON(t *testing.T) {
 		config: CollectionConfig{PrimaryKey: "name"},
 	}
 
-	data, err := json.Marshal(collection)
+	data, err := json.Marshal(&collection)
 
 	assert.NoError(t, err, "unexpected error during marshalling")
 
@@ -41,12 +41,16 @@ func TestUnmarshalJSON(t *testing.T) {
 				Fields: map[string]DocumentField{
 					"name": {Type: DocumentFieldTypeString, Value: "doc1"},
 				},
+				// Documents dumped without a revision get one on load
+				Revision: 1,
 			},
 		},
-		config: CollectionConfig{PrimaryKey: "name"},
+		config:   CollectionConfig{PrimaryKey: "name"},
+		index:    map[string]*CollectionIndex{},
+		revision: 1,
 	}
 
-	assert.Equal(t, expectedCollection, collection, "unmarshalled collection does not match the expected result")
+	assert.Equal(t, &expectedCollection, &collection, "unmarshalled collection does not match the expected result")
 }
 
 func TestPut(t *testing.T) {
@@ -62,10 +66,12 @@ func TestPut(t *testing.T) {
 		},
 	}
 
-	collection.Put(doc)
+	_, err := collection.Put(doc)
+	assert.NoError(t, err, "unexpected error during put")
 
 	storedDoc, ok := collection.docs["key1"]
 	assert.True(t, ok, "document with key 'key1' was not added to the collection")
+	doc.Revision = 1
 	assert.Equal(t, doc, storedDoc, "stored document does not match the original")
 }
 
@@ -85,15 +91,17 @@ func TestGet(t *testing.T) {
 	collection.Put(doc)
 
 	// Test existing key
-	getDoc, ok := collection.Get("key1")
-	assert.True(t, ok, "document with key 'key1' should exist")
+	getDoc, err := collection.Get("key1")
+	assert.NoError(t, err, "document with key 'key1' should exist")
 	assert.NotNil(t, getDoc, "document pointer should not be nil")
+	assert.Equal(t, uint64(1), getDoc.Revision, "the first write should get revision 1")
+	doc.Revision = 1
 
 	assert.Equal(t, doc, *getDoc, "retrieved document does not match the expected one")
 
 	// Test non-existing key
-	_, ok = collection.Get("key2")
-	assert.False(t, ok, "document with key 'key2' should not exist")
+	_, err = collection.Get("key2")
+	assert.ErrorIs(t, err, ErrDocumentNotFound, "document with key 'key2' should not exist")
 }
 
 func TestDelete(t *testing.T) {
@@ -108,15 +116,38 @@ func TestDelete(t *testing.T) {
 	}
 
 	// Test deletion of an existing key
-	success := collection.Delete("key1")
-	assert.True(t, success, "expected key 'key1' to be deleted successfully")
+	err := collection.Delete("key1")
+	assert.NoError(t, err, "expected key 'key1' to be deleted successfully")
 
 	_, exists := collection.docs["key1"]
 	assert.False(t, exists, "key 'key1' should no longer exist in the collection")
 
 	// Test deletion of a non-existing key
-	success = collection.Delete("key2")
-	assert.False(t, success, "deleting a non-existing key should return false")
+	err = collection.Delete("key2")
+	assert.ErrorIs(t, err, ErrDocumentNotFound, "deleting a non-existing key should return ErrDocumentNotFound")
+}
+
+func TestPutInvalidPrimaryKey(t *testing.T) {
+	collection := Collection{
+		docs:   make(map[string]Document),
+		config: CollectionConfig{PrimaryKey: "id"},
+	}
+
+	tests := []struct {
+		name   string
+		fields map[string]DocumentField
+		err    error
+	}{
+		{"missing", map[string]DocumentField{"name": {Type: DocumentFieldTypeString, Value: "doc"}}, ErrMissingPrimaryKey},
+		{"wrong type", map[string]DocumentField{"id": {Type: DocumentFieldTypeNumber, Value: 1.0}}, ErrPrimaryKeyNotString},
+		{"wrong value", map[string]DocumentField{"id": {Type: DocumentFieldTypeString, Value: 1.0}}, ErrPrimaryKeyNotString},
+		{"empty", map[string]DocumentField{"id": {Type: DocumentFieldTypeString, Value: ""}}, ErrEmptyPrimaryKey},
+	}
+	for _, tt := range tests {
+		_, err := collection.Put(Document{Fields: tt.fields})
+		assert.ErrorIs(t, err, tt.err, "unexpected error for %s primary key", tt.name)
+	}
+	assert.Empty(t, collection.docs, "invalid documents should not be stored")
 }
 
 func TestList(t *testing.T) {
@@ -0,0 +1,125 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Log segment that is being folded into the snapshot. It only exists while a
+// compaction is running or if the process died in the middle of one.
+const walCompactingFileName = "wal.log.compacting"
+
+type CompactionConfig struct {
+	MaxMutations int           // Compact after this many logged changes, 0 disables it
+	Interval     time.Duration // Compact at least this often, 0 disables it
+}
+
+// StartCompaction runs a background goroutine that writes a fresh snapshot and
+// drops the log it supersedes. Works only for stores created by `OpenStore`.
+func (s *Store) StartCompaction(cfg CompactionConfig) error {
+	if s.wal == nil {
+		return errors.New("compaction requires a store opened with OpenStore")
+	}
+	if s.stopCompaction != nil {
+		return errors.New("compaction is already running")
+	}
+	s.wal.limit.Store(int64(cfg.MaxMutations))
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	s.stopCompaction = stop
+	s.compactionDone = done
+
+	go func() {
+		defer close(done)
+		var tick <-chan time.Time
+		if cfg.Interval > 0 {
+			ticker := time.NewTicker(cfg.Interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-stop:
+				return
+			case <-tick:
+			case <-s.wal.full:
+			}
+			if s.wal.mutations.Load() == 0 {
+				continue
+			}
+			if err := s.Compact(); err != nil {
+				logger.Error("Compaction failed", "error", err)
+			}
+		}
+	}()
+	logger.Info("Compaction started", "maxMutations", cfg.MaxMutations, "interval", cfg.Interval)
+	return nil
+}
+
+func (s *Store) stopCompactionLoop() {
+	if s.stopCompaction == nil {
+		return
+	}
+	close(s.stopCompaction)
+	<-s.compactionDone
+	s.stopCompaction = nil
+}
+
+// Compact folds the current write-ahead log into the snapshot. The log is
+// rotated first so writers are never blocked while the snapshot is built.
+func (s *Store) Compact() error {
+	if s.wal == nil {
+		return errors.New("compaction requires a store opened with OpenStore")
+	}
+	s.compactMx.Lock()
+	defer s.compactMx.Unlock()
+
+	segment := filepath.Join(s.dir, walCompactingFileName)
+	// Leftover of a failed compaction has to land in the snapshot before the
+	// log is rotated again, otherwise records would be replayed out of order
+	if _, err := os.Stat(segment); err == nil {
+		if err := s.mergeWALSegment(segment); err != nil {
+			return err
+		}
+	}
+	if err := s.wal.rotate(segment); err != nil {
+		return fmt.Errorf("error rotating wal: %w", err)
+	}
+	return s.mergeWALSegment(segment)
+}
+
+// mergeWALSegment builds the new snapshot from the previous snapshot and the
+// segment instead of the live store, so it doesn't race with concurrent writes.
+// Replaying puts and deletes is idempotent, so if we crash after the rename
+// but before the segment is removed, replaying it once more on open is harmless.
+func (s *Store) mergeWALSegment(segment string) error {
+	snapshotPath := filepath.Join(s.dir, snapshotFileName)
+	base, err := loadSnapshot(snapshotPath)
+	if err != nil {
+		return err
+	}
+	if err := replayWAL(segment, base); err != nil {
+		return err
+	}
+	if err := base.DumpToFile(snapshotPath); err != nil {
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	if err := os.Remove(segment); err != nil {
+		return err
+	}
+	logger.Info("Wal compacted", "snapshot", snapshotPath)
+	return syncDir(s.dir)
+}
+
+func loadSnapshot(filename string) (*Store, error) {
+	if _, err := os.Stat(filename); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return NewStore(), nil
+		}
+		return nil, err
+	}
+	return NewStoreFromFile(filename)
+}
@@ -0,0 +1,107 @@
+package documentstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	assert.NoError(t, err)
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(testDocument("key1", "val1"))
+	col.Put(testDocument("key2", "val2"))
+
+	assert.NoError(t, store.Compact(), "Compact should not return an error")
+
+	info, err := os.Stat(filepath.Join(dir, walFileName))
+	assert.NoError(t, err)
+	assert.Zero(t, info.Size(), "the compacted log should be truncated")
+	_, err = os.Stat(filepath.Join(dir, walCompactingFileName))
+	assert.True(t, os.IsNotExist(err), "the compacted segment should be removed")
+
+	col.Delete("key1")
+	assert.NoError(t, store.Close())
+
+	restored, err := OpenStore(dir)
+	assert.NoError(t, err)
+	defer restored.Close()
+	col, err = restored.GetCollection("users")
+	assert.NoError(t, err, "collection should be restored from the snapshot")
+	_, err = col.Get("key1")
+	assert.ErrorIs(t, err, ErrDocumentNotFound, "delete logged after compaction should be replayed")
+	_, err = col.Get("key2")
+	assert.NoError(t, err, "document from the snapshot should be restored")
+}
+
+func TestCompactRecoversInterruptedSegment(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	assert.NoError(t, err)
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(testDocument("key1", "val1"))
+	assert.NoError(t, store.Close())
+
+	// Simulate a crash right after the log was rotated
+	assert.NoError(t, os.Rename(filepath.Join(dir, walFileName), filepath.Join(dir, walCompactingFileName)))
+
+	restored, err := OpenStore(dir)
+	assert.NoError(t, err)
+	col, err = restored.GetCollection("users")
+	assert.NoError(t, err, "collection from the interrupted segment should be replayed")
+	col.Put(testDocument("key2", "val2"))
+
+	assert.NoError(t, restored.Compact())
+	assert.NoError(t, restored.Close())
+
+	snapshot, err := NewStoreFromFile(filepath.Join(dir, snapshotFileName))
+	assert.NoError(t, err, "snapshot should be readable")
+	col, err = snapshot.GetCollection("users")
+	assert.NoError(t, err)
+	assert.Len(t, col.List(), 2, "snapshot should contain both segments")
+}
+
+func TestStartCompactionAfterMaxMutations(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.NoError(t, store.StartCompaction(CompactionConfig{MaxMutations: 3}))
+	assert.Error(t, store.StartCompaction(CompactionConfig{MaxMutations: 3}), "compactor should only be started once")
+
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(testDocument("key1", "val1"))
+	col.Put(testDocument("key2", "val2"))
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, snapshotFileName))
+		return err == nil
+	}, time.Second, 10*time.Millisecond, "snapshot should be written once the mutation limit is reached")
+}
+
+func TestDumpToFileLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "dump.json")
+
+	store := NewStore()
+	store.CreateCollection("test_collection", &CollectionConfig{PrimaryKey: "id"})
+	assert.NoError(t, store.DumpToFile(fileName))
+	store.DeleteCollection("test_collection")
+	assert.NoError(t, store.DumpToFile(fileName), "overwriting an existing dump should work")
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "only the dump itself should be left in the directory")
+
+	restored, err := NewStoreFromFile(fileName)
+	assert.NoError(t, err)
+	assert.Empty(t, restored.collections, "the dump should reflect the latest state")
+}
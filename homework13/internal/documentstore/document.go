@@ -1,5 +1,12 @@
 package documentstore
 
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
 type DocumentFieldType string
 
 const (
@@ -17,4 +24,166 @@ type DocumentField struct {
 
 type Document struct {
 	Fields map[string]DocumentField
+	// Revision is assigned by the collection on every write. Values set by
+	// callers are ignored.
+	Revision uint64 `json:",omitempty"`
+	// ExpiresAt hides the document from reads once reached and lets the
+	// reaper remove it. Nil means the collection's TTL applies, if any.
+	ExpiresAt *time.Time `json:",omitempty"`
+	// TTLExpiresAt is when the collection's TTL runs out, counted from the
+	// last write. It's set by the collection, values set by callers are
+	// ignored.
+	TTLExpiresAt *time.Time `json:",omitempty"`
+}
+
+// Validate returns ErrInvalidDocument if a field's value doesn't match its
+// type. Collections don't call it, it's meant for documents coming from
+// outside, e.g. decoded from JSON.
+func (d Document) Validate() error {
+	for name, field := range d.Fields {
+		if err := field.Validate(); err != nil {
+			return fmt.Errorf("%w: field %q: %w", ErrInvalidDocument, name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the value against the field type. Numbers may be of any Go
+// numeric type, arrays any slice and objects either map[string]interface{}
+// or map[string]DocumentField.
+func (f DocumentField) Validate() error {
+	var ok bool
+	switch f.Type {
+	case DocumentFieldTypeString:
+		_, ok = f.Value.(string)
+	case DocumentFieldTypeNumber:
+		_, ok = toFloat64(f.Value)
+	case DocumentFieldTypeBool:
+		_, ok = f.Value.(bool)
+	case DocumentFieldTypeArray:
+		ok = f.Value != nil && reflect.TypeOf(f.Value).Kind() == reflect.Slice
+	case DocumentFieldTypeObject:
+		switch f.Value.(type) {
+		case map[string]interface{}, map[string]DocumentField:
+			ok = true
+		}
+	default:
+		return fmt.Errorf("unknown type %q", f.Type)
+	}
+	if !ok {
+		return fmt.Errorf("%T value doesn't match type %q", f.Value, f.Type)
+	}
+	return nil
+}
+
+// UnmarshalJSON keeps objects of typed fields typed, so they read the same
+// after a round trip through a dump, the WAL or the wire. JSON can't tell them
+// from plain objects, so an object is decoded as map[string]DocumentField
+// when every value in it is a valid field with exactly the keys "Type" and
+// "Value".
+func (f *DocumentField) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type  DocumentFieldType
+		Value json.RawMessage
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	f.Type = raw.Type
+	f.Value = nil
+	if raw.Type == DocumentFieldTypeObject {
+		if fields, ok := typedFields(raw.Value); ok {
+			f.Value = fields
+			return nil
+		}
+	}
+	if len(raw.Value) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw.Value, &f.Value)
+}
+
+func typedFields(data json.RawMessage) (map[string]DocumentField, bool) {
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil || len(entries) == 0 {
+		return nil, false
+	}
+	fields := make(map[string]DocumentField, len(entries))
+	for name, entry := range entries {
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(entry, &keys); err != nil || len(keys) != 2 {
+			return nil, false
+		}
+		if _, ok := keys["Type"]; !ok {
+			return nil, false
+		}
+		if _, ok := keys["Value"]; !ok {
+			return nil, false
+		}
+		var field DocumentField
+		if err := json.Unmarshal(entry, &field); err != nil || field.Validate() != nil {
+			return nil, false
+		}
+		fields[name] = field
+	}
+	return fields, true
+}
+
+// Clone returns a deep copy of the document, including nested arrays and
+// objects. Collections store and hand out clones, so changing a document
+// passed to or returned from a collection never changes stored data.
+func (d Document) Clone() Document {
+	clone := Document{Revision: d.Revision, ExpiresAt: cloneTime(d.ExpiresAt), TTLExpiresAt: cloneTime(d.TTLExpiresAt)}
+	if d.Fields != nil {
+		clone.Fields = make(map[string]DocumentField, len(d.Fields))
+		for name, field := range d.Fields {
+			clone.Fields[name] = field.Clone()
+		}
+	}
+	return clone
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	clone := *t
+	return &clone
+}
+
+func (f DocumentField) Clone() DocumentField {
+	return DocumentField{Type: f.Type, Value: cloneValue(f.Value)}
+}
+
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[k] = cloneValue(item)
+		}
+		return result
+	case map[string]DocumentField:
+		result := make(map[string]DocumentField, len(v))
+		for k, item := range v {
+			result[k] = item.Clone()
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = cloneValue(item)
+		}
+		return result
+	case DocumentField:
+		return v.Clone()
+	}
+	// Other slices, e.g. []string, only hold immutable values
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice && !rv.IsNil() {
+		result := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		reflect.Copy(result, rv)
+		return result.Interface()
+	}
+	return value
 }
@@ -0,0 +1,140 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func nestedDocument() Document {
+	return Document{Fields: map[string]DocumentField{
+		"id":    {Type: DocumentFieldTypeString, Value: "key1"},
+		"tags":  {Type: DocumentFieldTypeArray, Value: []interface{}{"a", map[string]interface{}{"b": "c"}}},
+		"names": {Type: DocumentFieldTypeArray, Value: []string{"x", "y"}},
+		"address": {Type: DocumentFieldTypeObject, Value: map[string]interface{}{
+			"geo": map[string]interface{}{"zip": float64(1)},
+		}},
+		"typed": {Type: DocumentFieldTypeObject, Value: map[string]DocumentField{
+			"inner": {Type: DocumentFieldTypeArray, Value: []interface{}{"d"}},
+		}},
+	}}
+}
+
+func mutateDocument(doc Document) {
+	doc.Fields["id"] = DocumentField{Type: DocumentFieldTypeString, Value: "changed"}
+	doc.Fields["new"] = DocumentField{Type: DocumentFieldTypeBool, Value: true}
+	doc.Fields["tags"].Value.([]interface{})[0] = "changed"
+	doc.Fields["tags"].Value.([]interface{})[1].(map[string]interface{})["b"] = "changed"
+	doc.Fields["names"].Value.([]string)[0] = "changed"
+	doc.Fields["address"].Value.(map[string]interface{})["geo"].(map[string]interface{})["zip"] = float64(2)
+	doc.Fields["typed"].Value.(map[string]DocumentField)["inner"].Value.([]interface{})[0] = "changed"
+}
+
+func TestDocumentClone(t *testing.T) {
+	doc := nestedDocument()
+	clone := doc.Clone()
+	assert.Equal(t, doc, clone, "clone should be equal to the original")
+
+	mutateDocument(clone)
+	assert.Equal(t, nestedDocument(), doc, "changing the clone should not change the original")
+}
+
+func TestDocumentCloneExpiresAt(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	doc := Document{ExpiresAt: &expiresAt}
+	clone := doc.Clone()
+	assert.Equal(t, doc, clone, "clone should be equal to the original")
+
+	*clone.ExpiresAt = clone.ExpiresAt.Add(time.Hour)
+	assert.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), *doc.ExpiresAt, "changing the clone should not change the original")
+}
+
+func TestReadsReturnCopies(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("test", &CollectionConfig{PrimaryKey: "id"})
+	assert.NoError(t, col.CreateIndex("id"))
+
+	doc := nestedDocument()
+	_, err := col.Put(doc)
+	assert.NoError(t, err)
+	mutateDocument(doc)
+
+	got, _ := col.Get("key1")
+	mutateDocument(*got)
+	mutateDocument(col.List()[0])
+	found, _ := col.Find(Exists("id"))
+	mutateDocument(found[0])
+	queried, _ := col.Query("id", QueryParams{})
+	mutateDocument(queried[0])
+	page, _ := col.ListPage(PageOptions{})
+	mutateDocument(page.Documents[0])
+	updated, _ := col.Update("key1", SetField("other", BoolValue(true)))
+	mutateDocument(*updated)
+
+	stored, _ := col.Get("key1")
+	expected := nestedDocument()
+	expected.Fields["other"] = DocumentField{Type: DocumentFieldTypeBool, Value: true}
+	expected.Revision = stored.Revision
+	assert.Equal(t, expected, *stored, "documents passed to or returned from the collection should not share data with it")
+}
+
+func TestDocumentValidate(t *testing.T) {
+	assert.NoError(t, nestedDocument().Validate(), "well-typed document should be valid")
+	assert.NoError(t, Document{Fields: map[string]DocumentField{
+		"n":  {Type: DocumentFieldTypeNumber, Value: 3},
+		"b":  {Type: DocumentFieldTypeBool, Value: false},
+		"ok": {Type: DocumentFieldTypeNumber, Value: float64(1.5)},
+	}}.Validate(), "any numeric value should be a valid number")
+
+	invalid := []DocumentField{
+		{Type: DocumentFieldTypeString, Value: 1.0},
+		{Type: DocumentFieldTypeNumber, Value: "1"},
+		{Type: DocumentFieldTypeBool, Value: "true"},
+		{Type: DocumentFieldTypeArray, Value: map[string]interface{}{}},
+		{Type: DocumentFieldTypeArray, Value: nil},
+		{Type: DocumentFieldTypeObject, Value: []interface{}{}},
+		{Type: "date", Value: "2024-01-01"},
+	}
+	for _, field := range invalid {
+		doc := Document{Fields: map[string]DocumentField{"id": field}}
+		assert.ErrorIs(t, doc.Validate(), ErrInvalidDocument, "field %+v should be rejected", field)
+	}
+}
+
+func TestDocumentJSONKeepsTypedObjects(t *testing.T) {
+	doc := nestedDocument()
+	data, err := json.Marshal(doc)
+	assert.NoError(t, err)
+	var decoded Document
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, map[string]DocumentField{
+		"inner": {Type: DocumentFieldTypeArray, Value: []interface{}{"d"}},
+	}, decoded.Fields["typed"].Value, "objects of typed fields should stay typed")
+	assert.Equal(t, doc.Fields["address"], decoded.Fields["address"], "plain objects should stay plain")
+}
+
+func TestTypedObjectsSurviveDump(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("people", &CollectionConfig{PrimaryKey: "id"})
+	_, err := col.Put(Document{Fields: map[string]DocumentField{
+		"id": {Type: DocumentFieldTypeString, Value: "p1"},
+		"address": {Type: DocumentFieldTypeObject, Value: map[string]DocumentField{
+			"city": {Type: DocumentFieldTypeString, Value: "Kyiv"},
+		}},
+	}})
+	assert.NoError(t, err)
+	docs, _ := col.Find(Eq("address.city", StringValue("Kyiv")))
+	assert.Len(t, docs, 1)
+
+	dump, err := store.Dump()
+	assert.NoError(t, err)
+	restored, err := NewStoreFromDump(dump)
+	assert.NoError(t, err)
+	col, err = restored.GetCollection("people")
+	assert.NoError(t, err)
+	docs, err = col.Find(Eq("address.city", StringValue("Kyiv")))
+	assert.NoError(t, err)
+	assert.Len(t, docs, 1, "nested typed fields should be found after a dump")
+}
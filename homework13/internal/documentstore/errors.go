@@ -0,0 +1,64 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrMissingPrimaryKey       = errors.New("document has no primary key field")
+	ErrPrimaryKeyNotString     = errors.New("primary key field is not a string")
+	ErrEmptyPrimaryKey         = errors.New("primary key is empty")
+	ErrDocumentNotFound        = errors.New("document not found")
+	ErrCollectionNotFound      = errors.New("collection not found")
+	ErrCollectionAlreadyExists = errors.New("collection already exists")
+	ErrNilCollectionConfig     = errors.New("collection config is nil")
+	ErrIndexNotFound           = errors.New("index not found")
+	ErrIndexAlreadyExists      = errors.New("index already exists")
+	ErrInvalidIndex            = errors.New("invalid index")
+	ErrInvalidQuery            = errors.New("invalid query params")
+	ErrUniqueConstraint        = errors.New("unique constraint violation")
+	ErrInvalidFilter           = errors.New("invalid filter")
+	ErrInvalidCursor           = errors.New("invalid cursor")
+	ErrInvalidPageOptions      = errors.New("invalid page options")
+	ErrInvalidSelect           = errors.New("invalid select options")
+	ErrInvalidAggregation      = errors.New("invalid aggregation")
+	ErrInvalidUpdate           = errors.New("invalid update")
+	ErrRevisionConflict        = errors.New("revision conflict")
+	ErrTxDone                  = errors.New("transaction is already committed or rolled back")
+	ErrWatcherOverflow         = errors.New("watcher fell too far behind")
+	ErrInvalidDocument         = errors.New("invalid document")
+	ErrRecordTooLarge          = errors.New("record exceeds the wal size limit")
+	ErrCorruptWAL              = errors.New("wal is corrupt")
+)
+
+// UniqueConstraintError is returned by `Put` when the document's value of a
+// unique index already belongs to another document.
+type UniqueConstraintError struct {
+	Index          string
+	ConflictingKey string
+}
+
+func (e *UniqueConstraintError) Error() string {
+	return fmt.Sprintf("%s: index %s already has this value for document %s", ErrUniqueConstraint, e.Index, e.ConflictingKey)
+}
+
+func (e *UniqueConstraintError) Unwrap() error {
+	return ErrUniqueConstraint
+}
+
+// RevisionConflictError is returned by conditional writes when the document's
+// current revision is not the expected one. Actual is 0 if there is no document.
+type RevisionConflictError struct {
+	Key      string
+	Expected uint64
+	Actual   uint64
+}
+
+func (e *RevisionConflictError) Error() string {
+	return fmt.Sprintf("%s: document %s has revision %d, expected %d", ErrRevisionConflict, e.Key, e.Actual, e.Expected)
+}
+
+func (e *RevisionConflictError) Unwrap() error {
+	return ErrRevisionConflict
+}
@@ -0,0 +1,352 @@
+package documentstore
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type FilterOp string
+
+const (
+	FilterOpEq     FilterOp = "eq"
+	FilterOpNe     FilterOp = "ne"
+	FilterOpGt     FilterOp = "gt"
+	FilterOpGte    FilterOp = "gte"
+	FilterOpLt     FilterOp = "lt"
+	FilterOpLte    FilterOp = "lte"
+	FilterOpIn     FilterOp = "in"
+	FilterOpExists FilterOp = "exists"
+	FilterOpAnd    FilterOp = "and"
+	FilterOpOr     FilterOp = "or"
+	FilterOpNot    FilterOp = "not"
+)
+
+// Filter is a predicate over the fields of a document. Path addresses values
+// of nested objects with dots, e.g. `address.city`. Comparisons only match
+// values of the same type, the same way typed index bounds do.
+type Filter struct {
+	Op      FilterOp        `json:"op"`
+	Path    string          `json:"path,omitempty"`
+	Value   *DocumentField  `json:"value,omitempty"`
+	Values  []DocumentField `json:"values,omitempty"`
+	Filters []Filter        `json:"filters,omitempty"`
+}
+
+func Eq(path string, value *DocumentField) Filter {
+	return Filter{Op: FilterOpEq, Path: path, Value: value}
+}
+
+func Ne(path string, value *DocumentField) Filter {
+	return Filter{Op: FilterOpNe, Path: path, Value: value}
+}
+
+func Gt(path string, value *DocumentField) Filter {
+	return Filter{Op: FilterOpGt, Path: path, Value: value}
+}
+
+func Gte(path string, value *DocumentField) Filter {
+	return Filter{Op: FilterOpGte, Path: path, Value: value}
+}
+
+func Lt(path string, value *DocumentField) Filter {
+	return Filter{Op: FilterOpLt, Path: path, Value: value}
+}
+
+func Lte(path string, value *DocumentField) Filter {
+	return Filter{Op: FilterOpLte, Path: path, Value: value}
+}
+
+func In(path string, values ...*DocumentField) Filter {
+	f := Filter{Op: FilterOpIn, Path: path, Values: make([]DocumentField, len(values))}
+	for i, value := range values {
+		f.Values[i] = *value
+	}
+	return f
+}
+
+func Exists(path string) Filter {
+	return Filter{Op: FilterOpExists, Path: path}
+}
+
+func And(filters ...Filter) Filter {
+	return Filter{Op: FilterOpAnd, Filters: filters}
+}
+
+func Or(filters ...Filter) Filter {
+	return Filter{Op: FilterOpOr, Filters: filters}
+}
+
+func Not(filter Filter) Filter {
+	return Filter{Op: FilterOpNot, Filters: []Filter{filter}}
+}
+
+// Validate returns ErrInvalidFilter if an operator lacks its operands.
+func (f Filter) Validate() error {
+	switch f.Op {
+	case FilterOpEq, FilterOpNe, FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte:
+		if f.Path == "" || f.Value == nil {
+			return fmt.Errorf("%w: %s needs a path and a value", ErrInvalidFilter, f.Op)
+		}
+	case FilterOpIn:
+		if f.Path == "" || len(f.Values) == 0 {
+			return fmt.Errorf("%w: %s needs a path and values", ErrInvalidFilter, f.Op)
+		}
+	case FilterOpExists:
+		if f.Path == "" {
+			return fmt.Errorf("%w: %s needs a path", ErrInvalidFilter, f.Op)
+		}
+	case FilterOpAnd, FilterOpOr:
+		if len(f.Filters) == 0 {
+			return fmt.Errorf("%w: %s needs at least one filter", ErrInvalidFilter, f.Op)
+		}
+	case FilterOpNot:
+		if len(f.Filters) != 1 {
+			return fmt.Errorf("%w: %s needs exactly one filter", ErrInvalidFilter, f.Op)
+		}
+	default:
+		return fmt.Errorf("%w: unknown operator %q", ErrInvalidFilter, f.Op)
+	}
+	for _, child := range f.Filters {
+		if err := child.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match evaluates the filter against the document.
+func (f Filter) Match(doc Document) bool {
+	switch f.Op {
+	case FilterOpAnd:
+		for _, child := range f.Filters {
+			if !child.Match(doc) {
+				return false
+			}
+		}
+		return true
+	case FilterOpOr:
+		for _, child := range f.Filters {
+			if child.Match(doc) {
+				return true
+			}
+		}
+		return false
+	case FilterOpNot:
+		return !f.Filters[0].Match(doc)
+	}
+
+	field, found := resolvePath(doc, f.Path)
+	switch f.Op {
+	case FilterOpExists:
+		return found
+	case FilterOpNe:
+		return !found || !equalFields(field, *f.Value)
+	case FilterOpIn:
+		if !found {
+			return false
+		}
+		for _, value := range f.Values {
+			if equalFields(field, value) {
+				return true
+			}
+		}
+		return false
+	}
+	if !found {
+		return false
+	}
+	if f.Op == FilterOpEq {
+		return equalFields(field, *f.Value)
+	}
+	c, ok := compareFields(field, *f.Value)
+	if !ok {
+		return false
+	}
+	switch f.Op {
+	case FilterOpGt:
+		return c > 0
+	case FilterOpGte:
+		return c >= 0
+	case FilterOpLt:
+		return c < 0
+	case FilterOpLte:
+		return c <= 0
+	}
+	return false
+}
+
+// resolvePath walks into object fields. Nested values carry no declared type,
+// so it is inferred from the Go value.
+func resolvePath(doc Document, path string) (DocumentField, bool) {
+	parts := strings.Split(path, ".")
+	field, ok := doc.Fields[parts[0]]
+	for _, part := range parts[1:] {
+		if !ok || field.Type != DocumentFieldTypeObject {
+			return DocumentField{}, false
+		}
+		switch obj := field.Value.(type) {
+		case map[string]interface{}:
+			var value interface{}
+			value, ok = obj[part]
+			field = fieldFromValue(value)
+		case map[string]DocumentField:
+			field, ok = obj[part]
+		default:
+			return DocumentField{}, false
+		}
+	}
+	return field, ok
+}
+
+func fieldFromValue(value interface{}) DocumentField {
+	switch v := value.(type) {
+	case DocumentField:
+		return v
+	case string:
+		return DocumentField{Type: DocumentFieldTypeString, Value: v}
+	case bool:
+		return DocumentField{Type: DocumentFieldTypeBool, Value: v}
+	case []interface{}:
+		return DocumentField{Type: DocumentFieldTypeArray, Value: v}
+	case map[string]interface{}, map[string]DocumentField:
+		return DocumentField{Type: DocumentFieldTypeObject, Value: v}
+	}
+	if _, ok := toFloat64(value); ok {
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: value}
+	}
+	return DocumentField{Value: value}
+}
+
+// compareFields orders string, number and bool values of the same type.
+func compareFields(a, b DocumentField) (int, bool) {
+	ka, ok := newIndexKey(a)
+	if !ok {
+		return 0, false
+	}
+	kb, ok := newIndexKey(b)
+	if !ok || ka.rank != kb.rank {
+		return 0, false
+	}
+	return ka.compare(kb), true
+}
+
+func equalFields(a, b DocumentField) bool {
+	if c, ok := compareFields(a, b); ok {
+		return c == 0
+	}
+	return a.Type == b.Type && reflect.DeepEqual(a.Value, b.Value)
+}
+
+type indexScan struct {
+	idx          *CollectionIndex
+	lower, upper *indexEntry
+}
+
+// indexFor picks an index whose first field is `path`, preferring the one with
+// the fewest fields. Nested paths are never indexed.
+func (s *Collection) indexFor(path string) *CollectionIndex {
+	var best *CollectionIndex
+	for _, idx := range s.index {
+		if idx.fields[0] == path && (best == nil || len(idx.fields) < len(best.fields)) {
+			best = idx
+		}
+	}
+	return best
+}
+
+// planFilter returns index ranges that contain every document matching the
+// filter, or false if the collection has to be scanned. The filter itself is
+// still evaluated on each candidate, so the ranges may be wider than needed
+// (e.g. inclusive for Gt and Lt).
+func (s *Collection) planFilter(f Filter) ([]indexScan, bool) {
+	switch f.Op {
+	case FilterOpAnd:
+		for _, child := range f.Filters {
+			if scans, ok := s.planFilter(child); ok {
+				return scans, true
+			}
+		}
+		return nil, false
+	case FilterOpOr:
+		var scans []indexScan
+		for _, child := range f.Filters {
+			childScans, ok := s.planFilter(child)
+			if !ok {
+				return nil, false
+			}
+			scans = append(scans, childScans...)
+		}
+		return scans, true
+	case FilterOpEq, FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte, FilterOpIn:
+	default:
+		return nil, false
+	}
+
+	idx := s.indexFor(f.Path)
+	if idx == nil {
+		return nil, false
+	}
+	var params []QueryParams
+	switch f.Op {
+	case FilterOpEq:
+		params = append(params, QueryParams{MinValue: f.Value, MaxValue: f.Value})
+	case FilterOpGt, FilterOpGte:
+		params = append(params, QueryParams{MinValue: f.Value})
+	case FilterOpLt, FilterOpLte:
+		params = append(params, QueryParams{MaxValue: f.Value})
+	case FilterOpIn:
+		for i := range f.Values {
+			params = append(params, QueryParams{MinValue: &f.Values[i], MaxValue: &f.Values[i]})
+		}
+	}
+	scans := make([]indexScan, 0, len(params))
+	for _, p := range params {
+		lower, upper, err := queryRange(idx, p)
+		if err != nil {
+			// Values that can't be indexed (arrays, objects) need a scan
+			return nil, false
+		}
+		scans = append(scans, indexScan{idx: idx, lower: lower, upper: upper})
+	}
+	return scans, true
+}
+
+// Find returns documents matching the filter ordered by primary key. It reads
+// candidates from an index when the filter allows it and scans all documents otherwise.
+func (s *Collection) Find(filter Filter) ([]Document, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	var keys []string
+	if scans, ok := s.planFilter(filter); ok {
+		seen := make(map[string]struct{})
+		for _, scan := range scans {
+			scan.idx.scan(scan.lower, scan.upper, false, func(_ *indexEntry, key string) bool {
+				if _, dup := seen[key]; !dup {
+					seen[key] = struct{}{}
+					keys = append(keys, key)
+				}
+				return true
+			})
+		}
+	} else {
+		keys = make([]string, 0, len(s.docs))
+		for key := range s.docs {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var result []Document
+	for _, key := range keys {
+		if doc, found := s.liveDoc(key); found && filter.Match(doc) {
+			result = append(result, doc.Clone())
+		}
+	}
+	return result, nil
+}
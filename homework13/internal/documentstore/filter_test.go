@@ -0,0 +1,131 @@
+package documentstore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newPeopleCollection(t *testing.T) *Collection {
+	store := NewStore()
+	col, err := store.CreateCollection("people", &CollectionConfig{PrimaryKey: "id"})
+	assert.NoError(t, err)
+	people := []struct {
+		name   string
+		age    float64
+		active bool
+		city   string
+	}{
+		{"Olena", 30, true, "Kyiv"},
+		{"Nazar", 25, false, "Lviv"},
+		{"Marko", 41, true, "Kyiv"},
+		{"Zenyk", 17, true, "Odesa"},
+	}
+	for i, p := range people {
+		_, err := col.Put(Document{Fields: map[string]DocumentField{
+			"id":     {Type: DocumentFieldTypeString, Value: fmt.Sprintf("key%d", i)},
+			"name":   {Type: DocumentFieldTypeString, Value: p.name},
+			"age":    {Type: DocumentFieldTypeNumber, Value: p.age},
+			"active": {Type: DocumentFieldTypeBool, Value: p.active},
+			"address": {Type: DocumentFieldTypeObject, Value: map[string]interface{}{
+				"city": p.city,
+				"geo":  map[string]interface{}{"zip": float64(10000 + i)},
+			}},
+		}})
+		assert.NoError(t, err)
+	}
+	// A document without most of the fields
+	_, err = col.Put(Document{Fields: map[string]DocumentField{
+		"id": {Type: DocumentFieldTypeString, Value: "key9"},
+	}})
+	assert.NoError(t, err)
+	return col
+}
+
+func findNames(t *testing.T, col *Collection, filter Filter) []string {
+	docs, err := col.Find(filter)
+	assert.NoError(t, err, "Find should not return an error")
+	names := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if name, ok := doc.Fields["name"]; ok {
+			names = append(names, name.Value.(string))
+		} else {
+			names = append(names, "-")
+		}
+	}
+	return names
+}
+
+func TestFind(t *testing.T) {
+	col := newPeopleCollection(t)
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{"eq", Eq("name", StringValue("Nazar")), []string{"Nazar"}},
+		{"ne", Ne("name", StringValue("Nazar")), []string{"Olena", "Marko", "Zenyk", "-"}},
+		{"gt", Gt("age", NumberValue(25)), []string{"Olena", "Marko"}},
+		{"gte", Gte("age", NumberValue(25)), []string{"Olena", "Nazar", "Marko"}},
+		{"lt", Lt("age", NumberValue(30)), []string{"Nazar", "Zenyk"}},
+		{"lte", Lte("age", NumberValue(30)), []string{"Olena", "Nazar", "Zenyk"}},
+		{"type mismatch", Gt("age", StringValue("1")), []string{}},
+		{"in", In("name", StringValue("Marko"), StringValue("Zenyk"), StringValue("Taras")), []string{"Marko", "Zenyk"}},
+		{"exists", Exists("age"), []string{"Olena", "Nazar", "Marko", "Zenyk"}},
+		{"nested", Eq("address.city", StringValue("Kyiv")), []string{"Olena", "Marko"}},
+		{"deeply nested", Gte("address.geo.zip", NumberValue(10002)), []string{"Marko", "Zenyk"}},
+		{"nested exists", Exists("address.geo.zip"), []string{"Olena", "Nazar", "Marko", "Zenyk"}},
+		{"missing nested", Exists("name.first"), []string{}},
+		{"and", And(Eq("active", BoolValue(true)), Gte("age", NumberValue(18))), []string{"Olena", "Marko"}},
+		{"or", Or(Eq("name", StringValue("Nazar")), Lt("age", NumberValue(18))), []string{"Nazar", "Zenyk"}},
+		{"not", Not(Exists("name")), []string{"-"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, findNames(t, col, tt.filter), "unexpected result for %s filter", tt.name)
+	}
+}
+
+func TestFindUsesIndex(t *testing.T) {
+	col := newPeopleCollection(t)
+	assert.NoError(t, col.CreateIndex("age"))
+	assert.NoError(t, col.CreateIndex("name", "age"))
+
+	_, ok := col.planFilter(Gt("age", NumberValue(25)))
+	assert.True(t, ok, "comparison on an indexed field should use the index")
+	scans, ok := col.planFilter(Eq("name", StringValue("Nazar")))
+	assert.True(t, ok, "leading field of a compound index should use the index")
+	assert.Equal(t, []string{"name", "age"}, scans[0].idx.fields)
+	_, ok = col.planFilter(And(Eq("active", BoolValue(true)), Lt("age", NumberValue(30))))
+	assert.True(t, ok, "and should use an index of any of its operands")
+	_, ok = col.planFilter(Or(Eq("active", BoolValue(true)), Lt("age", NumberValue(30))))
+	assert.False(t, ok, "or with a non-indexed operand needs a scan")
+	_, ok = col.planFilter(Eq("address.city", StringValue("Kyiv")))
+	assert.False(t, ok, "nested paths need a scan")
+	assert.ErrorIs(t, col.CreateIndex("address.city"), ErrInvalidIndex, "nested paths can't be indexed")
+
+	// Index and scan have to agree
+	assert.Equal(t, []string{"Olena", "Marko"}, findNames(t, col, Gt("age", NumberValue(25))))
+	assert.Equal(t, []string{"Nazar", "Zenyk"}, findNames(t, col, Lt("age", NumberValue(30))))
+	assert.Equal(t, []string{"Nazar", "Marko"}, findNames(t, col, In("age", NumberValue(25), NumberValue(41))))
+	assert.Equal(t, []string{"Nazar", "Zenyk"},
+		findNames(t, col, Or(Eq("name", StringValue("Nazar")), Lt("age", NumberValue(18)))))
+}
+
+func TestFindInvalidFilter(t *testing.T) {
+	col := newPeopleCollection(t)
+
+	invalid := []Filter{
+		{Op: FilterOpEq, Path: "name"},
+		{Op: FilterOpIn, Path: "name"},
+		{Op: FilterOpExists},
+		{Op: FilterOpAnd},
+		{Op: FilterOpNot, Filters: []Filter{Exists("a"), Exists("b")}},
+		And(Exists("name"), Filter{Op: "like", Path: "name"}),
+	}
+	for _, filter := range invalid {
+		_, err := col.Find(filter)
+		assert.ErrorIs(t, err, ErrInvalidFilter, "filter %+v should be rejected", filter)
+	}
+}
@@ -0,0 +1,513 @@
+package documentstore
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/btree"
+)
+
+// CollectionIndex keeps the indexed values ordered in a B-tree. Every entry
+// holds the primary keys of all documents that have this value. Compound
+// indexes use the values of all their fields, in order, as the entry key.
+type CollectionIndex struct {
+	fields []string
+	unique bool
+	tree   *btree.BTree
+}
+
+// Compound indexes are named by their fields joined with a comma, e.g. `country,city`.
+const indexFieldSeparator = ","
+
+func indexName(fields []string) string {
+	return strings.Join(fields, indexFieldSeparator)
+}
+
+func indexFields(name string) []string {
+	return strings.Split(name, indexFieldSeparator)
+}
+
+func newCollectionIndex(fields []string) *CollectionIndex {
+	return &CollectionIndex{fields: fields, tree: btree.New(32)}
+}
+
+// Values of different types are ordered by type first (bool < number < string),
+// so a single index can hold documents with differently typed fields.
+const (
+	indexRankBool = iota
+	indexRankNumber
+	indexRankString
+	// Sorts after any real value, used to build upper bounds for prefixes
+	indexRankMax
+)
+
+type indexKey struct {
+	rank int
+	b    bool
+	num  float64
+	str  string
+}
+
+// compare orders numbers numerically, strings lexically and false before true.
+func (a indexKey) compare(b indexKey) int {
+	if a.rank != b.rank {
+		return cmp.Compare(a.rank, b.rank)
+	}
+	switch a.rank {
+	case indexRankBool:
+		if a.b == b.b {
+			return 0
+		}
+		if !a.b {
+			return -1
+		}
+		return 1
+	case indexRankNumber:
+		return cmp.Compare(a.num, b.num)
+	case indexRankString:
+		return cmp.Compare(a.str, b.str)
+	}
+	return 0
+}
+
+type indexEntry struct {
+	key  []indexKey
+	docs map[string]struct{}
+}
+
+// Implement the Less method for B-Tree ordering.
+// Keys are compared value by value, a prefix goes before any longer key that starts with it.
+func (a *indexEntry) Less(item btree.Item) bool {
+	b := item.(*indexEntry)
+	for i := 0; i < len(a.key) && i < len(b.key); i++ {
+		if c := a.key[i].compare(b.key[i]); c != 0 {
+			return c < 0
+		}
+	}
+	return len(a.key) < len(b.key)
+}
+
+// lowestIndexKey is the smallest possible key of the given rank.
+func lowestIndexKey(rank int) indexKey {
+	if rank == indexRankNumber {
+		return indexKey{rank: rank, num: math.Inf(-1)}
+	}
+	return indexKey{rank: rank}
+}
+
+// highestIndexKey is the largest key of the given rank. There is none for
+// strings, which are ranked last anyway.
+func highestIndexKey(rank int) (indexKey, bool) {
+	switch rank {
+	case indexRankBool:
+		return indexKey{rank: rank, b: true}, true
+	case indexRankNumber:
+		return indexKey{rank: rank, num: math.Inf(1)}, true
+	}
+	return indexKey{}, false
+}
+
+// newIndexKey honors the declared type of the field: only string, number and
+// bool fields whose value matches their `Type` can be indexed. NaN and ±Inf
+// aren't indexed, they can't be put in a JSON cursor.
+func newIndexKey(field DocumentField) (indexKey, bool) {
+	switch field.Type {
+	case DocumentFieldTypeString:
+		str, ok := field.Value.(string)
+		return indexKey{rank: indexRankString, str: str}, ok
+	case DocumentFieldTypeNumber:
+		num, ok := toFloat64(field.Value)
+		if !ok || math.IsNaN(num) || math.IsInf(num, 0) {
+			return indexKey{}, false
+		}
+		return indexKey{rank: indexRankNumber, num: num}, true
+	case DocumentFieldTypeBool:
+		b, ok := field.Value.(bool)
+		return indexKey{rank: indexRankBool, b: b}, ok
+	}
+	return indexKey{}, false
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Індексуватись мають тільки поля типу string, number та bool. Якщо у документа поле має інший тип або взагалі поле відсутнє - воно не попадає в індекс
+// For compound indexes every field has to be indexable.
+func (idx *CollectionIndex) keyOf(doc Document) ([]indexKey, bool) {
+	key := make([]indexKey, len(idx.fields))
+	for i, fieldName := range idx.fields {
+		field, ok := doc.Fields[fieldName]
+		if !ok {
+			return nil, false
+		}
+		if key[i], ok = newIndexKey(field); !ok {
+			return nil, false
+		}
+	}
+	return key, true
+}
+
+func (idx *CollectionIndex) add(key string, doc Document) {
+	val, ok := idx.keyOf(doc)
+	if !ok {
+		return
+	}
+	entry, _ := idx.tree.Get(&indexEntry{key: val}).(*indexEntry)
+	if entry == nil {
+		entry = &indexEntry{key: val, docs: make(map[string]struct{})}
+		idx.tree.ReplaceOrInsert(entry)
+	}
+	entry.docs[key] = struct{}{}
+}
+
+// remove drops only `key` from the value's entry, the value itself leaves the
+// tree once no other document has it.
+func (idx *CollectionIndex) remove(key string, doc Document) {
+	val, ok := idx.keyOf(doc)
+	if !ok {
+		return
+	}
+	entry, _ := idx.tree.Get(&indexEntry{key: val}).(*indexEntry)
+	if entry == nil {
+		return
+	}
+	delete(entry.docs, key)
+	if len(entry.docs) == 0 {
+		idx.tree.Delete(entry)
+	}
+}
+
+// checkUnique reports whether storing `doc` under `key` would give a value of
+// a unique index to a second document.
+func (idx *CollectionIndex) checkUnique(key string, doc Document) error {
+	if !idx.unique {
+		return nil
+	}
+	val, ok := idx.keyOf(doc)
+	if !ok {
+		return nil
+	}
+	entry, _ := idx.tree.Get(&indexEntry{key: val}).(*indexEntry)
+	if entry == nil {
+		return nil
+	}
+	for other := range entry.docs {
+		if other != key {
+			return &UniqueConstraintError{Index: indexName(idx.fields), ConflictingKey: other}
+		}
+	}
+	return nil
+}
+
+// keys returns primary keys of the entry's documents in a stable order.
+func (e *indexEntry) keys() []string {
+	keys := make([]string, 0, len(e.docs))
+	for key := range e.docs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// updateIndex has to be called with the collection's write lock held, `old`
+// is the document previously stored under `key` (if any).
+func (s *Collection) updateIndex(key string, old *Document, doc *Document) {
+	for _, idx := range s.index {
+		if old != nil {
+			idx.remove(key, *old)
+		}
+		if doc != nil {
+			idx.add(key, *doc)
+		}
+	}
+}
+
+// buildIndex fails only for unique indexes when existing documents already share a value.
+func (s *Collection) buildIndex(fields []string, unique bool) (*CollectionIndex, error) {
+	idx := newCollectionIndex(fields)
+	idx.unique = unique
+	for key, doc := range s.docs {
+		if err := idx.checkUnique(key, doc); err != nil {
+			return nil, err
+		}
+		idx.add(key, doc)
+	}
+	return idx, nil
+}
+
+// checkUniqueIndexes has to be called with the collection's write lock held.
+// Expired documents holding the value are removed instead of failing the check.
+func (s *Collection) checkUniqueIndexes(key string, doc Document) error {
+	for _, idx := range s.index {
+		for {
+			err := idx.checkUnique(key, doc)
+			var conflict *UniqueConstraintError
+			if !errors.As(err, &conflict) {
+				if err != nil {
+					return err
+				}
+				break
+			}
+			other, ok := s.docs[conflict.ConflictingKey]
+			if !ok || !other.expired(timeNow()) {
+				return err
+			}
+			if err := s.dropExpired(conflict.ConflictingKey); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Collection) indexNames(unique bool) []string {
+	names := make([]string, 0, len(s.index))
+	for name, idx := range s.index {
+		if idx.unique == unique {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CreateIndex indexes one field or, when several are given, builds a compound
+// index ordered by the first field, then by the second one and so on.
+func (s *Collection) CreateIndex(fieldNames ...string) error {
+	return s.createIndex(fieldNames, false)
+}
+
+// CreateUniqueIndex works like `CreateIndex`, but afterwards `Put` rejects a
+// document whose indexed value already belongs to a different primary key.
+// Fails if existing documents already violate the constraint.
+func (s *Collection) CreateUniqueIndex(fieldNames ...string) error {
+	return s.createIndex(fieldNames, true)
+}
+
+func (s *Collection) createIndex(fieldNames []string, unique bool) error {
+	if len(fieldNames) == 0 {
+		return fmt.Errorf("%w: needs at least one field", ErrInvalidIndex)
+	}
+	for _, field := range fieldNames {
+		// Filters read a dot as a path into nested objects, an index on a
+		// top-level key holding one would be used for the wrong values
+		if field == "" || strings.ContainsAny(field, indexFieldSeparator+".") {
+			return fmt.Errorf("%w: field name %q", ErrInvalidIndex, field)
+		}
+	}
+	fieldName := indexName(fieldNames)
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if err := s.checkDropped(); err != nil {
+		return err
+	}
+	// Якщо індекс вже існує - повертаємо помилку
+	if _, ok := s.index[fieldName]; ok {
+		return fmt.Errorf("%w: %s", ErrIndexAlreadyExists, fieldName)
+	}
+	idx, err := s.buildIndex(fieldNames, unique)
+	if err != nil {
+		return err
+	}
+	if s.wal != nil {
+		err := s.wal.append(walRecord{Op: walOpCreateIndex, Collection: s.name, Field: fieldName, Unique: unique})
+		if err != nil {
+			return err
+		}
+	}
+	if s.index == nil {
+		s.index = make(map[string]*CollectionIndex)
+	}
+	s.index[fieldName] = idx
+	return nil
+}
+
+// DeleteIndex takes the same field names the index was created with.
+func (s *Collection) DeleteIndex(fieldNames ...string) error {
+	fieldName := indexName(fieldNames)
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if err := s.checkDropped(); err != nil {
+		return err
+	}
+	if _, ok := s.index[fieldName]; !ok {
+		return fmt.Errorf("%w: %s", ErrIndexNotFound, fieldName)
+	}
+	if s.wal != nil {
+		err := s.wal.append(walRecord{Op: walOpDeleteIndex, Collection: s.name, Field: fieldName})
+		if err != nil {
+			return err
+		}
+	}
+	delete(s.index, fieldName)
+	return nil
+}
+
+type QueryParams struct {
+	Desc     bool            // Визначає в якому порядку повертати дані
+	Prefix   []DocumentField // Exact values of the leading fields of a compound index
+	MinValue *DocumentField  // Визначає мінімальне значення поля для фільтрації
+	MaxValue *DocumentField  // Визначає максимальне значення поля для фільтрації
+}
+
+// StringValue, NumberValue and BoolValue build typed bounds for QueryParams.
+func StringValue(v string) *DocumentField {
+	return &DocumentField{Type: DocumentFieldTypeString, Value: v}
+}
+
+func NumberValue(v float64) *DocumentField {
+	return &DocumentField{Type: DocumentFieldTypeNumber, Value: v}
+}
+
+func BoolValue(v bool) *DocumentField {
+	return &DocumentField{Type: DocumentFieldTypeBool, Value: v}
+}
+
+// queryRange turns the params into the lowest and highest entry to visit. The
+// bounds apply to the field right after the prefix; a bound restricts the
+// query to values of its own type, so both bounds have to be of the same type.
+func queryRange(idx *CollectionIndex, params QueryParams) (*indexEntry, *indexEntry, error) {
+	hasBounds := params.MinValue != nil || params.MaxValue != nil
+	if len(params.Prefix) > len(idx.fields) || (hasBounds && len(params.Prefix) == len(idx.fields)) {
+		return nil, nil, fmt.Errorf("%w: index %s has only %d fields", ErrInvalidQuery, indexName(idx.fields), len(idx.fields))
+	}
+	prefix := make([]indexKey, len(params.Prefix))
+	for i, field := range params.Prefix {
+		key, ok := newIndexKey(field)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: prefix value %v of type %s", ErrInvalidQuery, field.Value, field.Type)
+		}
+		prefix[i] = key
+	}
+
+	var min, max *indexKey
+	if params.MinValue != nil {
+		key, ok := newIndexKey(*params.MinValue)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: min value %v of type %s", ErrInvalidQuery, params.MinValue.Value, params.MinValue.Type)
+		}
+		min = &key
+	}
+	if params.MaxValue != nil {
+		key, ok := newIndexKey(*params.MaxValue)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: max value %v of type %s", ErrInvalidQuery, params.MaxValue.Value, params.MaxValue.Type)
+		}
+		max = &key
+	}
+	if min != nil && max != nil && min.rank != max.rank {
+		return nil, nil, fmt.Errorf("%w: min value of type %s and max value of type %s can't be mixed", ErrInvalidQuery, params.MinValue.Type, params.MaxValue.Type)
+	}
+	// A single bound still has to keep values of other types out
+	if min == nil && max != nil {
+		key := lowestIndexKey(max.rank)
+		min = &key
+	}
+	if max == nil && min != nil {
+		if key, ok := highestIndexKey(min.rank); ok {
+			max = &key
+		}
+	}
+
+	lower := &indexEntry{key: prefix}
+	if min != nil {
+		lower.key = append(slices.Clone(prefix), *min)
+	}
+	// Longer keys sort after their prefix, so the upper bound is closed with a
+	// sentinel that is greater than any value of the remaining fields
+	upper := &indexEntry{key: append(slices.Clone(prefix), indexKey{rank: indexRankMax})}
+	if max != nil {
+		upper.key = append(slices.Clone(prefix), *max, indexKey{rank: indexRankMax})
+	}
+	return lower, upper, nil
+}
+
+// Query returns documents whose indexed field lies within [MinValue, MaxValue],
+// ordered by that field and then by primary key. Both bounds are optional and inclusive.
+// For a compound index `fieldName` is its fields joined with a comma; any leading
+// fields can be pinned with Prefix and the bounds then apply to the next one.
+func (s *Collection) Query(fieldName string, params QueryParams) ([]Document, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	// Якщо для даного поля не існує індекса - повертаємо помилку
+	idx, ok := s.index[fieldName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrIndexNotFound, fieldName)
+	}
+	lower, upper, err := queryRange(idx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Document
+	idx.scan(lower, upper, params.Desc, func(_ *indexEntry, key string) bool {
+		if doc, found := s.liveDoc(key); found {
+			result = append(result, doc.Clone())
+		}
+		return true
+	})
+	return result, nil
+}
+
+// scan calls fn with the primary keys of all entries between lower and upper
+// until fn returns false.
+func (idx *CollectionIndex) scan(lower, upper *indexEntry, desc bool, fn func(entry *indexEntry, key string) bool) {
+	iterator := func(item btree.Item) bool {
+		entry := item.(*indexEntry)
+		if desc && entry.Less(lower) {
+			return false
+		}
+		if !desc && upper.Less(entry) {
+			return false
+		}
+		for _, key := range entry.keys() {
+			if !fn(entry, key) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if desc {
+		idx.tree.DescendLessOrEqual(upper, iterator)
+	} else {
+		idx.tree.AscendGreaterOrEqual(lower, iterator)
+	}
+}
@@ -0,0 +1,312 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newIndexedCollection(t *testing.T) *Collection {
+	store := NewStore()
+	col, _ := store.CreateCollection("test_collection", &CollectionConfig{PrimaryKey: "id"})
+	for i := 0; i < 5; i++ {
+		col.Put(testDocument(fmt.Sprintf("key%d", i), fmt.Sprintf("val%d", i)))
+	}
+	assert.NoError(t, col.CreateIndex("val"), "CreateIndex should not return an error")
+	return col
+}
+
+func queryValues(t *testing.T, col *Collection, params QueryParams) []string {
+	docs, err := col.Query("val", params)
+	assert.NoError(t, err, "Query should not return an error")
+	values := make([]string, len(docs))
+	for i, doc := range docs {
+		values[i] = doc.Fields["val"].Value.(string)
+	}
+	return values
+}
+
+func TestCreateIndex(t *testing.T) {
+	col := newIndexedCollection(t)
+
+	assert.Error(t, col.CreateIndex("val"), "creating an existing index should fail")
+	assert.NoError(t, col.DeleteIndex("val"))
+	assert.Error(t, col.DeleteIndex("val"), "deleting a missing index should fail")
+	assert.ErrorIs(t, col.CreateIndex(), ErrInvalidIndex, "an index needs a field")
+	assert.ErrorIs(t, col.CreateIndex("a,b"), ErrInvalidIndex, "field names can't hold the separator")
+
+	_, err := col.Query("val", QueryParams{})
+	assert.Error(t, err, "querying a field without index should fail")
+}
+
+func TestQuery(t *testing.T) {
+	col := newIndexedCollection(t)
+
+	assert.Equal(t, []string{"val1", "val2", "val3"},
+		queryValues(t, col, QueryParams{MinValue: StringValue("val1"), MaxValue: StringValue("val3")}))
+	assert.Equal(t, []string{"val3", "val2", "val1"},
+		queryValues(t, col, QueryParams{Desc: true, MinValue: StringValue("val1"), MaxValue: StringValue("val3")}))
+	assert.Equal(t, []string{"val4", "val3"},
+		queryValues(t, col, QueryParams{Desc: true, MinValue: StringValue("val3")}))
+	assert.Equal(t, []string{"val0", "val1"},
+		queryValues(t, col, QueryParams{MaxValue: StringValue("val1")}))
+}
+
+func TestQueryAfterPutAndDelete(t *testing.T) {
+	col := newIndexedCollection(t)
+
+	col.Put(testDocument("key1", "val9"))
+	col.Delete("key2")
+	col.Put(testDocument("key5", "val5"))
+
+	assert.Equal(t, []string{"val0", "val3", "val4", "val5", "val9"}, queryValues(t, col, QueryParams{}))
+}
+
+func TestIndexSurvivesDump(t *testing.T) {
+	col := newIndexedCollection(t)
+
+	data, err := json.Marshal(col)
+	assert.NoError(t, err)
+
+	var restored Collection
+	assert.NoError(t, json.Unmarshal(data, &restored))
+	assert.Equal(t, []string{"val3", "val4"}, queryValues(t, &restored, QueryParams{MinValue: StringValue("val3")}))
+}
+
+func TestIndexSurvivesWALReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	assert.NoError(t, err)
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(testDocument("key1", "val1"))
+	assert.NoError(t, col.CreateIndex("val"))
+	assert.NoError(t, store.Close())
+
+	restored, err := OpenStore(dir)
+	assert.NoError(t, err)
+	defer restored.Close()
+	col, _ = restored.GetCollection("users")
+	assert.Equal(t, []string{"val1"}, queryValues(t, col, QueryParams{}))
+}
+
+func TestQueryConcurrentWithPut(t *testing.T) {
+	col := newIndexedCollection(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			col.Put(testDocument(fmt.Sprintf("key%d", i), fmt.Sprintf("val%d", i)))
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := col.Query("val", QueryParams{MinValue: StringValue("val1")})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	docs, err := col.Query("val", QueryParams{})
+	assert.NoError(t, err)
+	assert.Len(t, docs, 50)
+}
+
+func TestQueryDuplicateValues(t *testing.T) {
+	col := newIndexedCollection(t)
+
+	col.Put(testDocument("key5", "val1"))
+	col.Put(testDocument("key6", "val1"))
+
+	docs, err := col.Query("val", QueryParams{MinValue: StringValue("val1"), MaxValue: StringValue("val1")})
+	assert.NoError(t, err)
+	keys := make([]string, len(docs))
+	for i, doc := range docs {
+		keys[i] = doc.Fields["id"].Value.(string)
+	}
+	assert.Equal(t, []string{"key1", "key5", "key6"}, keys, "all documents with the same value should be returned")
+
+	// Deleting one of them must keep the others in the index
+	col.Delete("key5")
+	col.Put(testDocument("key6", "val7"))
+	assert.Equal(t, []string{"val0", "val1", "val2", "val3", "val4", "val7"}, queryValues(t, col, QueryParams{}))
+}
+
+func TestQueryNumberIndex(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("test_collection", &CollectionConfig{PrimaryKey: "id"})
+	for i, age := range []interface{}{9, 10.5, int64(100), 2} {
+		col.Put(Document{Fields: map[string]DocumentField{
+			"id":  {Type: DocumentFieldTypeString, Value: fmt.Sprintf("key%d", i)},
+			"age": {Type: DocumentFieldTypeNumber, Value: age},
+		}})
+	}
+	// Mismatched Type/Value pairs and other types are not indexed
+	col.Put(Document{Fields: map[string]DocumentField{
+		"id":  {Type: DocumentFieldTypeString, Value: "key4"},
+		"age": {Type: DocumentFieldTypeNumber, Value: "50"},
+	}})
+	col.Put(Document{Fields: map[string]DocumentField{
+		"id":  {Type: DocumentFieldTypeString, Value: "key5"},
+		"age": {Type: DocumentFieldTypeString, Value: "50"},
+	}})
+	assert.NoError(t, col.CreateIndex("age"))
+
+	ids := func(docs []Document) []string {
+		keys := make([]string, len(docs))
+		for i, doc := range docs {
+			keys[i] = doc.Fields["id"].Value.(string)
+		}
+		return keys
+	}
+
+	docs, err := col.Query("age", QueryParams{MinValue: NumberValue(5), MaxValue: NumberValue(100)})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key0", "key1", "key2"}, ids(docs), "numbers should be ordered numerically")
+
+	docs, err = col.Query("age", QueryParams{Desc: true, MaxValue: NumberValue(10)})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key0", "key3"}, ids(docs), "a number bound should not match strings")
+
+	docs, err = col.Query("age", QueryParams{MinValue: StringValue("0")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key5"}, ids(docs), "a string bound should only match strings")
+
+	_, err = col.Query("age", QueryParams{MinValue: NumberValue(1), MaxValue: StringValue("9")})
+	assert.ErrorIs(t, err, ErrInvalidQuery, "bounds of different types can't be mixed")
+	_, err = col.Query("age", QueryParams{MinValue: &DocumentField{Type: DocumentFieldTypeNumber, Value: "1"}})
+	assert.ErrorIs(t, err, ErrInvalidQuery, "bound with mismatched type should be rejected")
+}
+
+func TestQueryBoolIndex(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("test_collection", &CollectionConfig{PrimaryKey: "id"})
+	for i, active := range []bool{true, false, true} {
+		col.Put(Document{Fields: map[string]DocumentField{
+			"id":     {Type: DocumentFieldTypeString, Value: fmt.Sprintf("key%d", i)},
+			"active": {Type: DocumentFieldTypeBool, Value: active},
+		}})
+	}
+	assert.NoError(t, col.CreateIndex("active"))
+
+	docs, err := col.Query("active", QueryParams{MinValue: BoolValue(false)})
+	assert.NoError(t, err)
+	assert.Len(t, docs, 3)
+	assert.Equal(t, false, docs[0].Fields["active"].Value, "false should go before true")
+
+	docs, err = col.Query("active", QueryParams{MinValue: BoolValue(true), MaxValue: BoolValue(true)})
+	assert.NoError(t, err)
+	assert.Len(t, docs, 2)
+}
+
+func TestQueryCompoundIndex(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("test_collection", &CollectionConfig{PrimaryKey: "id"})
+	places := [][]string{
+		{"UA", "Lviv"}, {"UA", "Kyiv"}, {"PL", "Krakow"}, {"UA", "Odesa"}, {"PL", "Warsaw"}, {"UA", "Kyiv"},
+	}
+	for i, place := range places {
+		col.Put(Document{Fields: map[string]DocumentField{
+			"id":      {Type: DocumentFieldTypeString, Value: fmt.Sprintf("key%d", i)},
+			"country": {Type: DocumentFieldTypeString, Value: place[0]},
+			"city":    {Type: DocumentFieldTypeString, Value: place[1]},
+		}})
+	}
+	// Documents missing one of the fields are not indexed
+	col.Put(Document{Fields: map[string]DocumentField{
+		"id":      {Type: DocumentFieldTypeString, Value: "key6"},
+		"country": {Type: DocumentFieldTypeString, Value: "UA"},
+	}})
+	assert.NoError(t, col.CreateIndex("country", "city"))
+	assert.Error(t, col.CreateIndex("country", "city"), "creating an existing compound index should fail")
+
+	cities := func(params QueryParams) []string {
+		docs, err := col.Query("country,city", params)
+		assert.NoError(t, err)
+		values := make([]string, len(docs))
+		for i, doc := range docs {
+			values[i] = doc.Fields["city"].Value.(string)
+		}
+		return values
+	}
+
+	assert.Equal(t, []string{"Krakow", "Warsaw", "Kyiv", "Kyiv", "Lviv", "Odesa"}, cities(QueryParams{}))
+	assert.Equal(t, []string{"Odesa", "Lviv", "Kyiv", "Kyiv"},
+		cities(QueryParams{Desc: true, Prefix: []DocumentField{*StringValue("UA")}}))
+	assert.Equal(t, []string{"Kyiv", "Kyiv", "Lviv"},
+		cities(QueryParams{Prefix: []DocumentField{*StringValue("UA")}, MaxValue: StringValue("Lviv")}))
+	assert.Equal(t, []string{"Lviv", "Kyiv", "Kyiv"},
+		cities(QueryParams{Desc: true, Prefix: []DocumentField{*StringValue("UA")}, MaxValue: StringValue("Lviv")}))
+	assert.Equal(t, []string{"Kyiv", "Kyiv"},
+		cities(QueryParams{Prefix: []DocumentField{*StringValue("UA"), *StringValue("Kyiv")}}))
+	assert.Equal(t, []string{"Krakow", "Warsaw"},
+		cities(QueryParams{MinValue: StringValue("A"), MaxValue: StringValue("PL")}), "bounds without prefix apply to the first field")
+
+	_, err := col.Query("country,city", QueryParams{
+		Prefix:   []DocumentField{*StringValue("UA"), *StringValue("Kyiv")},
+		MinValue: StringValue("A"),
+	})
+	assert.Error(t, err, "bounds need a field after the prefix")
+
+	assert.NoError(t, col.DeleteIndex("country", "city"))
+	_, err = col.Query("country,city", QueryParams{})
+	assert.Error(t, err)
+}
+
+func TestUniqueIndex(t *testing.T) {
+	col := newIndexedCollection(t)
+	assert.NoError(t, col.DeleteIndex("val"))
+	assert.NoError(t, col.CreateUniqueIndex("id", "val"))
+	assert.NoError(t, col.DeleteIndex("id", "val"))
+	assert.NoError(t, col.CreateUniqueIndex("val"), "existing values are unique")
+
+	_, err := col.Put(testDocument("key9", "val1"))
+	assert.ErrorIs(t, err, ErrUniqueConstraint, "value of another document should be rejected")
+	var uniqueErr *UniqueConstraintError
+	assert.ErrorAs(t, err, &uniqueErr)
+	assert.Equal(t, "key1", uniqueErr.ConflictingKey)
+	_, err = col.Get("key9")
+	assert.ErrorIs(t, err, ErrDocumentNotFound, "rejected document should not be stored")
+
+	_, err = col.Put(testDocument("key1", "val1"))
+	assert.NoError(t, err, "document may keep its own value")
+	_, err = col.Put(testDocument("key1", "val7"))
+	assert.NoError(t, err)
+	_, err = col.Put(testDocument("key9", "val1"))
+	assert.NoError(t, err, "released value can be taken by another document")
+
+	col.Put(testDocument("key8", "dup"))
+	assert.NoError(t, col.DeleteIndex("val"))
+	col.Put(testDocument("key7", "dup"))
+	assert.ErrorIs(t, col.CreateUniqueIndex("val"), ErrUniqueConstraint, "index can't be created over duplicates")
+}
+
+func TestUniqueIndexSurvivesWALReplayAndDump(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	assert.NoError(t, err)
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(testDocument("key1", "val1"))
+	assert.NoError(t, col.CreateUniqueIndex("val"))
+	assert.NoError(t, store.Close())
+
+	restored, err := OpenStore(dir)
+	assert.NoError(t, err)
+	col, _ = restored.GetCollection("users")
+	_, err = col.Put(testDocument("key2", "val1"))
+	assert.ErrorIs(t, err, ErrUniqueConstraint, "unique index should be restored from the wal")
+	assert.NoError(t, restored.Compact())
+	assert.NoError(t, restored.Close())
+
+	restored, err = OpenStore(dir)
+	assert.NoError(t, err)
+	defer restored.Close()
+	col, _ = restored.GetCollection("users")
+	_, err = col.Put(testDocument("key2", "val1"))
+	assert.ErrorIs(t, err, ErrUniqueConstraint, "unique index should be restored from the snapshot")
+}
@@ -0,0 +1,189 @@
+package documentstore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+type PageOptions struct {
+	Limit  int    // Maximum number of documents in the page, 0 means no limit
+	Offset int    // Number of documents to skip after the cursor position
+	Cursor string // NextCursor of the previous page, empty for the first one
+}
+
+type Page struct {
+	Documents  []Document
+	NextCursor string // Empty when there are no more documents
+}
+
+// pageCursor is the position of the last returned document: its primary key
+// and, for queries, the index entry it was found in.
+type pageCursor struct {
+	Key   string          `json:"k"`
+	Value []DocumentField `json:"v,omitempty"`
+}
+
+func (c pageCursor) encode() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("error encoding cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string) (*pageCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+	return &c, nil
+}
+
+func (o PageOptions) validate() error {
+	if o.Limit < 0 || o.Offset < 0 {
+		return fmt.Errorf("%w: limit and offset can't be negative", ErrInvalidPageOptions)
+	}
+	return nil
+}
+
+// pager collects documents of one page; add returns false once the page is full.
+type pager struct {
+	opts    PageOptions
+	skipped int
+	page    Page
+	last    pageCursor
+	more    bool
+}
+
+func (p *pager) add(doc Document, cursor pageCursor) bool {
+	if p.skipped < p.opts.Offset {
+		p.skipped++
+		return true
+	}
+	if p.opts.Limit > 0 && len(p.page.Documents) == p.opts.Limit {
+		p.more = true
+		return false
+	}
+	p.page.Documents = append(p.page.Documents, doc.Clone())
+	p.last = cursor
+	return true
+}
+
+func (p *pager) result() (*Page, error) {
+	if p.more {
+		cursor, err := p.last.encode()
+		if err != nil {
+			return nil, err
+		}
+		p.page.NextCursor = cursor
+	}
+	return &p.page, nil
+}
+
+// ListPage returns documents ordered by primary key. A cursor stays valid
+// when documents are added or removed between pages.
+func (s *Collection) ListPage(opts PageOptions) (*Page, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	keys := s.sortedKeys()
+	start := 0
+	if cursor != nil {
+		start = sort.Search(len(keys), func(i int) bool { return keys[i] > cursor.Key })
+	}
+	p := &pager{opts: opts}
+	for _, key := range keys[start:] {
+		doc, found := s.liveDoc(key)
+		if !found {
+			continue
+		}
+		if !p.add(doc, pageCursor{Key: key}) {
+			break
+		}
+	}
+	return p.result()
+}
+
+// QueryPage works like `Query` but returns one page of the result.
+func (s *Collection) QueryPage(fieldName string, params QueryParams, opts PageOptions) (*Page, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	idx, ok := s.index[fieldName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrIndexNotFound, fieldName)
+	}
+	lower, upper, err := queryRange(idx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var resume *indexEntry
+	if cursor != nil {
+		resume = &indexEntry{key: make([]indexKey, len(cursor.Value))}
+		for i, field := range cursor.Value {
+			if resume.key[i], ok = newIndexKey(field); !ok {
+				return nil, fmt.Errorf("%w: bad index value", ErrInvalidCursor)
+			}
+		}
+		// Jump straight to the entry of the last returned document
+		if params.Desc && resume.Less(upper) {
+			upper = resume
+		}
+		if !params.Desc && lower.Less(resume) {
+			lower = resume
+		}
+	}
+
+	p := &pager{opts: opts}
+	idx.scan(lower, upper, params.Desc, func(entry *indexEntry, key string) bool {
+		// Keys within an entry are always ascending, skip the ones already returned
+		if resume != nil && !entry.Less(resume) && !resume.Less(entry) && key <= cursor.Key {
+			return true
+		}
+		doc, found := s.liveDoc(key)
+		if !found {
+			return true
+		}
+		return p.add(doc, pageCursor{Key: key, Value: entry.fields()})
+	})
+	return p.result()
+}
+
+// fields converts the entry key back to values so it can be put in a cursor.
+func (e *indexEntry) fields() []DocumentField {
+	fields := make([]DocumentField, len(e.key))
+	for i, k := range e.key {
+		switch k.rank {
+		case indexRankBool:
+			fields[i] = DocumentField{Type: DocumentFieldTypeBool, Value: k.b}
+		case indexRankNumber:
+			fields[i] = DocumentField{Type: DocumentFieldTypeNumber, Value: k.num}
+		default:
+			fields[i] = DocumentField{Type: DocumentFieldTypeString, Value: k.str}
+		}
+	}
+	return fields
+}
@@ -0,0 +1,116 @@
+package documentstore
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pageKeys(page *Page) []string {
+	keys := make([]string, len(page.Documents))
+	for i, doc := range page.Documents {
+		keys[i] = doc.Fields["id"].Value.(string)
+	}
+	return keys
+}
+
+func TestListPage(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("test_collection", &CollectionConfig{PrimaryKey: "id"})
+	for _, key := range []string{"key3", "key1", "key5", "key2", "key4"} {
+		col.Put(testDocument(key, "val"))
+	}
+
+	page, err := col.ListPage(PageOptions{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key1", "key2"}, pageKeys(page), "documents should be ordered by primary key")
+	assert.NotEmpty(t, page.NextCursor)
+
+	// Changes between pages must not shift the cursor
+	col.Delete("key1")
+	col.Put(testDocument("key0", "val"))
+
+	page, err = col.ListPage(PageOptions{Limit: 2, Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key3", "key4"}, pageKeys(page))
+
+	page, err = col.ListPage(PageOptions{Limit: 2, Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key5"}, pageKeys(page))
+	assert.Empty(t, page.NextCursor, "last page should not have a cursor")
+
+	page, err = col.ListPage(PageOptions{Offset: 1, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key2", "key3"}, pageKeys(page))
+
+	page, err = col.ListPage(PageOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, page.Documents, 5, "no limit should return everything")
+	assert.Empty(t, page.NextCursor)
+
+	_, err = col.ListPage(PageOptions{Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = col.ListPage(PageOptions{Limit: -1})
+	assert.ErrorIs(t, err, ErrInvalidPageOptions)
+}
+
+func TestQueryPage(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("test_collection", &CollectionConfig{PrimaryKey: "id"})
+	for i := 0; i < 7; i++ {
+		// Every value is shared by a few documents, pages have to split entries
+		col.Put(testDocument(fmt.Sprintf("key%d", i), fmt.Sprintf("val%d", i%3)))
+	}
+	assert.NoError(t, col.CreateIndex("val"))
+
+	for _, desc := range []bool{false, true} {
+		params := QueryParams{Desc: desc, MinValue: StringValue("val0")}
+		expected, err := col.Query("val", params)
+		assert.NoError(t, err)
+
+		var paged []Document
+		cursor := ""
+		for {
+			page, err := col.QueryPage("val", params, PageOptions{Limit: 2, Cursor: cursor})
+			assert.NoError(t, err)
+			paged = append(paged, page.Documents...)
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		assert.Equal(t, expected, paged, "pages should add up to the full query result (desc: %v)", desc)
+	}
+
+	page, err := col.QueryPage("val", QueryParams{}, PageOptions{Offset: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key2", "key5"}, pageKeys(page))
+
+	_, err = col.QueryPage("missing", QueryParams{}, PageOptions{})
+	assert.ErrorIs(t, err, ErrIndexNotFound)
+}
+
+func TestQueryPageSkipsInfiniteNumbers(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("test_collection", &CollectionConfig{PrimaryKey: "id"})
+	assert.NoError(t, col.CreateIndex("age"))
+	for i, age := range []float64{1, math.Inf(1), 2, math.Inf(-1)} {
+		col.Put(Document{Fields: map[string]DocumentField{
+			"id":  {Type: DocumentFieldTypeString, Value: fmt.Sprintf("key%d", i)},
+			"age": {Type: DocumentFieldTypeNumber, Value: age},
+		}})
+	}
+
+	page, err := col.QueryPage("age", QueryParams{}, PageOptions{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key0"}, pageKeys(page), "infinite numbers should not be indexed")
+	page, err = col.QueryPage("age", QueryParams{}, PageOptions{Limit: 1, Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key2"}, pageKeys(page))
+	assert.Empty(t, page.NextCursor)
+
+	_, err = col.Query("age", QueryParams{MinValue: NumberValue(math.Inf(-1))})
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
@@ -0,0 +1,101 @@
+package documentstore
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPutIf(t *testing.T) {
+	col := newPeopleCollection(t)
+
+	doc, _ := col.Get("key0")
+	rev, err := col.PutIf(testDocument("key0", "new"), doc.Revision)
+	assert.NoError(t, err, "PutIf with the current revision should succeed")
+	assert.Greater(t, rev, doc.Revision, "revision should increase")
+
+	_, err = col.PutIf(testDocument("key0", "stale"), doc.Revision)
+	var conflict *RevisionConflictError
+	assert.True(t, errors.As(err, &conflict), "stale revision should be rejected with a conflict")
+	assert.ErrorIs(t, err, ErrRevisionConflict)
+	assert.Equal(t, RevisionConflictError{Key: "key0", Expected: doc.Revision, Actual: rev}, *conflict)
+	stored, _ := col.Get("key0")
+	assert.Equal(t, "new", stored.Fields["val"].Value, "rejected put should not change the document")
+
+	_, err = col.PutIf(testDocument("key0", "again"), 0)
+	assert.ErrorIs(t, err, ErrRevisionConflict, "revision 0 should only match missing documents")
+	created, err := col.PutIf(testDocument("fresh", "val"), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, rev+1, created, "revisions should be unique within the collection")
+}
+
+func TestPutReturnsRevision(t *testing.T) {
+	col := newPeopleCollection(t)
+
+	rev, err := col.Put(testDocument("key0", "new"))
+	assert.NoError(t, err)
+	stored, _ := col.Get("key0")
+	assert.Equal(t, stored.Revision, rev, "Put should return the revision it assigned")
+	next, _ := col.Put(testDocument("key0", "newer"))
+	assert.Equal(t, rev+1, next)
+}
+
+func TestDeleteIf(t *testing.T) {
+	col := newPeopleCollection(t)
+
+	doc, _ := col.Get("key1")
+	assert.ErrorIs(t, col.DeleteIf("key1", doc.Revision+1), ErrRevisionConflict)
+	assert.NoError(t, col.DeleteIf("key1", doc.Revision))
+	assert.ErrorIs(t, col.DeleteIf("key1", doc.Revision), ErrDocumentNotFound)
+}
+
+func TestPutIfConcurrentWriters(t *testing.T) {
+	col := newPeopleCollection(t)
+	doc, _ := col.Get("key2")
+
+	var wg sync.WaitGroup
+	var mx sync.Mutex
+	succeeded := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := col.PutIf(testDocument("key2", "x"), doc.Revision); err == nil {
+				mx.Lock()
+				succeeded++
+				mx.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, succeeded, "only one writer should win with the same revision")
+}
+
+func TestRevisionsSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	assert.NoError(t, err)
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(testDocument("key1", "val1"))
+	col.Put(testDocument("key2", "val2"))
+	col.Delete("key2")
+	assert.NoError(t, store.Compact())
+	col.Put(testDocument("key1", "val3"))
+	doc, _ := col.Get("key1")
+	assert.NoError(t, store.Close())
+
+	restored, err := OpenStore(dir)
+	assert.NoError(t, err)
+	defer restored.Close()
+	col, _ = restored.GetCollection("users")
+	restoredDoc, err := col.Get("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, doc.Revision, restoredDoc.Revision, "revision should be restored from the wal")
+
+	rev, err := col.PutIf(testDocument("key2", "val4"), 0)
+	assert.NoError(t, err)
+	assert.Greater(t, rev, doc.Revision, "new revisions should continue after the restored ones")
+}
@@ -0,0 +1,145 @@
+package documentstore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type SortField struct {
+	Path string `json:"path"`
+	Desc bool   `json:"desc,omitempty"`
+}
+
+type SelectOptions struct {
+	Filter *Filter     // Nil matches every document
+	Fields []string    // Paths to keep in returned documents, empty keeps everything
+	Sort   []SortField // Applied in order, ties are broken by primary key
+	Limit  int         // 0 means no limit
+	Offset int
+}
+
+// Select returns the filtered documents sorted by the given fields and
+// trimmed to the projected ones. The primary key is always kept.
+func (s *Collection) Select(opts SelectOptions) ([]Document, error) {
+	if opts.Limit < 0 || opts.Offset < 0 {
+		return nil, fmt.Errorf("%w: limit and offset can't be negative", ErrInvalidPageOptions)
+	}
+	for _, field := range opts.Sort {
+		if field.Path == "" {
+			return nil, fmt.Errorf("%w: sort field needs a path", ErrInvalidSelect)
+		}
+	}
+	for _, path := range opts.Fields {
+		if path == "" {
+			return nil, fmt.Errorf("%w: empty projection path", ErrInvalidSelect)
+		}
+	}
+
+	var docs []Document
+	var err error
+	if opts.Filter != nil {
+		docs, err = s.Find(*opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		docs = s.List()
+	}
+
+	if len(opts.Sort) > 0 {
+		// Documents come ordered by primary key, a stable sort keeps it for ties
+		sort.SliceStable(docs, func(i, j int) bool {
+			return compareForSort(docs[i], docs[j], opts.Sort) < 0
+		})
+	}
+
+	if opts.Offset >= len(docs) {
+		return []Document{}, nil
+	}
+	docs = docs[opts.Offset:]
+	if opts.Limit > 0 && opts.Limit < len(docs) {
+		docs = docs[:opts.Limit]
+	}
+
+	if len(opts.Fields) == 0 {
+		return docs, nil
+	}
+	result := make([]Document, len(docs))
+	for i, doc := range docs {
+		result[i] = project(doc, opts.Fields, s.config.PrimaryKey)
+	}
+	return result, nil
+}
+
+// compareForSort orders values the same way indexes do. Documents missing the
+// field, or having a value that can't be ordered, go first.
+func compareForSort(a, b Document, fields []SortField) int {
+	for _, field := range fields {
+		fa, okA := resolvePath(a, field.Path)
+		fb, okB := resolvePath(b, field.Path)
+		c := compareSortValues(fa, okA, fb, okB)
+		if field.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareSortValues(a DocumentField, okA bool, b DocumentField, okB bool) int {
+	var ka, kb indexKey
+	if okA {
+		ka, okA = newIndexKey(a)
+	}
+	if okB {
+		kb, okB = newIndexKey(b)
+	}
+	switch {
+	case okA && okB:
+		return ka.compare(kb)
+	case okA:
+		return 1
+	case okB:
+		return -1
+	}
+	return 0
+}
+
+// project copies the requested paths into a new document. Nested paths keep
+// their parent objects, but only with the requested keys.
+func project(doc Document, paths []string, primaryKey string) Document {
+	result := Document{Fields: make(map[string]DocumentField)}
+	if field, ok := doc.Fields[primaryKey]; ok {
+		result.Fields[primaryKey] = field
+	}
+	for _, path := range paths {
+		field, ok := resolvePath(doc, path)
+		if !ok {
+			continue
+		}
+		parts := strings.Split(path, ".")
+		if len(parts) == 1 {
+			result.Fields[path] = field
+			continue
+		}
+		parent, ok := result.Fields[parts[0]]
+		obj, isObj := parent.Value.(map[string]interface{})
+		if !ok || !isObj {
+			obj = make(map[string]interface{})
+			result.Fields[parts[0]] = DocumentField{Type: DocumentFieldTypeObject, Value: obj}
+		}
+		for _, part := range parts[1 : len(parts)-1] {
+			next, isObj := obj[part].(map[string]interface{})
+			if !isObj {
+				next = make(map[string]interface{})
+				obj[part] = next
+			}
+			obj = next
+		}
+		obj[parts[len(parts)-1]] = field.Value
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package documentstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func selectNames(t *testing.T, col *Collection, opts SelectOptions) []string {
+	docs, err := col.Select(opts)
+	assert.NoError(t, err, "Select should not return an error")
+	names := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if name, ok := doc.Fields["name"]; ok {
+			names = append(names, name.Value.(string))
+		} else {
+			names = append(names, "-")
+		}
+	}
+	return names
+}
+
+func TestSelectSort(t *testing.T) {
+	col := newPeopleCollection(t)
+
+	assert.Equal(t, []string{"-", "Zenyk", "Nazar", "Olena", "Marko"},
+		selectNames(t, col, SelectOptions{Sort: []SortField{{Path: "age"}}}), "missing values should go first")
+	assert.Equal(t, []string{"Marko", "Olena", "Nazar", "Zenyk", "-"},
+		selectNames(t, col, SelectOptions{Sort: []SortField{{Path: "age", Desc: true}}}))
+	assert.Equal(t, []string{"-", "Olena", "Marko", "Nazar", "Zenyk"},
+		selectNames(t, col, SelectOptions{Sort: []SortField{{Path: "address.city"}}}), "ties should keep primary key order")
+	assert.Equal(t, []string{"-", "Marko", "Olena", "Nazar", "Zenyk"},
+		selectNames(t, col, SelectOptions{Sort: []SortField{{Path: "address.city"}, {Path: "age", Desc: true}}}))
+
+	active := Eq("active", BoolValue(true))
+	assert.Equal(t, []string{"Olena", "Marko"},
+		selectNames(t, col, SelectOptions{Filter: &active, Sort: []SortField{{Path: "name", Desc: true}}, Offset: 1, Limit: 2}))
+	assert.Empty(t, selectNames(t, col, SelectOptions{Offset: 10}))
+}
+
+func TestSelectProjection(t *testing.T) {
+	col := newPeopleCollection(t)
+
+	docs, err := col.Select(SelectOptions{Fields: []string{"name", "address.geo.zip", "missing"}, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []Document{{Fields: map[string]DocumentField{
+		"id":   {Type: DocumentFieldTypeString, Value: "key0"},
+		"name": {Type: DocumentFieldTypeString, Value: "Olena"},
+		"address": {Type: DocumentFieldTypeObject, Value: map[string]interface{}{
+			"geo": map[string]interface{}{"zip": float64(10000)},
+		}},
+	}}}, docs, "only the primary key and requested paths should be kept")
+
+	doc, _ := col.Get("key0")
+	assert.Len(t, doc.Fields["address"].Value.(map[string]interface{}), 2, "projection should not modify stored documents")
+}
+
+func TestSelectInvalidOptions(t *testing.T) {
+	col := newPeopleCollection(t)
+
+	_, err := col.Select(SelectOptions{Sort: []SortField{{}}})
+	assert.ErrorIs(t, err, ErrInvalidSelect)
+	_, err = col.Select(SelectOptions{Fields: []string{""}})
+	assert.ErrorIs(t, err, ErrInvalidSelect)
+	_, err = col.Select(SelectOptions{Limit: -1})
+	assert.ErrorIs(t, err, ErrInvalidPageOptions)
+	_, err = col.Select(SelectOptions{Filter: &Filter{Op: "bogus"}})
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+}
@@ -3,20 +3,44 @@ package documentstore
 import (
 	"bufio"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"sort"
+	"sync"
 )
 
 var logger = slog.Default()
 
+const (
+	snapshotFileName = "snapshot.json"
+	walFileName      = "wal.log"
+)
+
 type Store struct {
-	collections map[string]Collection
+	// mx guards the collections map, each collection has its own lock for documents
+	mx          sync.RWMutex
+	collections map[string]*Collection
+	wal *wal
+	dir string
+
+	compactMx      sync.Mutex
+	stopCompaction chan struct{}
+	compactionDone chan struct{}
+
+	reaperMx   sync.Mutex
+	stopReaper chan struct{}
+	reaperDone chan struct{}
 }
 
 func (s *Store) MarshalJSON() ([]byte, error) {
 	type Alias struct {
-		Collections map[string]Collection `json:"collections"`
+		Collections map[string]*Collection `json:"collections"`
 	}
+	s.mx.RLock()
+	defer s.mx.RUnlock()
 	alias := Alias{
 		Collections: s.collections,
 	}
@@ -27,7 +51,7 @@ func (s *Store) MarshalJSON() ([]byte, error) {
 func (s *Store) UnmarshalJSON(data []byte) error {
 	// Create an alias or temporary struct for unmarshalling
 	alias := struct {
-		Collections map[string]Collection `json:"collections"`
+		Collections map[string]*Collection `json:"collections"`
 	}{}
 
 	// Unmarshal into the alias
@@ -37,54 +61,97 @@ func (s *Store) UnmarshalJSON(data []byte) error {
 
 	// Set private field manually
 	s.collections = alias.Collections
+	for name, col := range s.collections {
+		col.name = name
+	}
 	return nil
 }
 
 func NewStore() *Store {
-	return &Store{collections: make(map[string]Collection)}
+	return &Store{collections: make(map[string]*Collection)}
 }
 
-func (s *Store) CreateCollection(name string, cfg *CollectionConfig) (bool, *Collection) {
-	// Створюємо нову колекцію і повертаємо `true` якщо колекція була створена
-	// Якщо ж колекція вже створеня то повертаємо `false` та nil
+// CreateCollection returns ErrCollectionAlreadyExists if the name is taken.
+func (s *Store) CreateCollection(name string, cfg *CollectionConfig) (*Collection, error) {
+	// Створюємо нову колекцію і повертаємо її якщо колекція була створена
+	// Якщо ж колекція вже створеня то повертаємо помилку
 	if cfg == nil {
 		logger.Warn("CollectionConfig is nil, cannot create collection", "name", name)
-		return false, nil
+		return nil, ErrNilCollectionConfig
 	}
-	col := Collection{docs: make(map[string]Document), config: *cfg}
+	col := &Collection{docs: make(map[string]Document), index: make(map[string]*CollectionIndex), config: *cfg, name: name, wal: s.wal}
 
+	s.mx.Lock()
+	defer s.mx.Unlock()
 	_, exists := s.collections[name]
 	if exists {
 		logger.Warn("Collection already exists", "name", name)
-		return false, nil
+		return nil, fmt.Errorf("%w: %s", ErrCollectionAlreadyExists, name)
+	}
+	if s.wal != nil {
+		err := s.wal.append(walRecord{Op: walOpCreateCollection, Collection: name, Config: cfg})
+		if err != nil {
+			logger.Error("Failed to log collection creation", "name", name, "error", err)
+			return nil, err
+		}
 	}
 
 	s.collections[name] = col
 	logger.Info("Collection created", "name", name)
-	return true, &col
+	return col, nil
 }
 
-func (s *Store) GetCollection(name string) (*Collection, bool) {
+// GetCollection returns ErrCollectionNotFound if there is no such collection.
+func (s *Store) GetCollection(name string) (*Collection, error) {
+	s.mx.RLock()
 	col, ok := s.collections[name]
+	s.mx.RUnlock()
 	if !ok {
 
 		logger.Warn("Collection not found", "name", name)
-		return nil, false
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
 	}
 	logger.Info("Collection retrieved", "name", name)
-	return &col, true
+	return col, nil
 }
 
-func (s *Store) DeleteCollection(name string) bool {
-	_, ok := s.collections[name]
+// DeleteCollection returns ErrCollectionNotFound if there is no such collection.
+// Later writes through pointers to the deleted collection fail with the same error.
+func (s *Store) DeleteCollection(name string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	col, ok := s.collections[name]
 	if !ok {
 
 		logger.Warn("Collection not found for deletion", "name", name)
-		return false
+		return fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+	}
+	if s.wal != nil {
+		err := s.wal.append(walRecord{Op: walOpDeleteCollection, Collection: name})
+		if err != nil {
+			logger.Error("Failed to log collection deletion", "name", name, "error", err)
+			return err
+		}
 	}
 	delete(s.collections, name)
+	col.mx.Lock()
+	col.dropped = true
+	col.stopWatchers(fmt.Errorf("%w: %s", ErrCollectionNotFound, name))
+	col.mx.Unlock()
 	logger.Info("Collection deleted", "name", name)
-	return true
+	return nil
+}
+
+// ListCollections returns the collection names in sorted order.
+func (s *Store) ListCollections() []string {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	names := make([]string, 0, len(s.collections))
+	for name := range s.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func NewStoreFromDump(dump []byte) (*Store, error) {
@@ -95,6 +162,9 @@ func NewStoreFromDump(dump []byte) (*Store, error) {
 	if err != nil {
 		return nil, err
 	}
+	if store.collections == nil {
+		store.collections = make(map[string]*Collection)
+	}
 	return  &store, nil
 }
 
@@ -127,7 +197,7 @@ func NewStoreFromFile(filename string) (*Store, error) {
 	}
 	reader := bufio.NewReader(file)
 	data := make([]byte, fileInfo.Size())
-	_, err = reader.Read(data)
+	_, err = io.ReadFull(reader, data)
 	if err != nil {
 		return nil, err
 	}
@@ -141,23 +211,98 @@ func (s *Store) DumpToFile(filename string) error {
 	if err != nil {
 		return err
 	}
-	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE, os.ModePerm)
+	// Write to a temp file next to the target and rename it over, so a crash
+	// mid-write never leaves a half-written dump behind
+	dir := filepath.Dir(filename)
+	file, err := os.CreateTemp(dir, filepath.Base(filename)+".tmp-*")
 	if err != nil {
 		return err
 	}
+	tmpName := file.Name()
 	defer func() {
-		err = file.Close()
 		if err != nil {
-			logger.Error("Failed to open file for writing", "filename", filename, "error", err)
+			file.Close()
+			os.Remove(tmpName)
 		}
 	}()
 
-	file.Truncate(0)
 	writer := bufio.NewWriter(file)
-	_, err = writer.Write(data)
+	if _, err = writer.Write(data); err != nil {
+		return err
+	}
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpName, filename); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir makes renames and file creations inside `dir` durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
 	if err != nil {
 		return err
 	}
-	writer.Flush()
-	return nil
+	defer d.Close()
+	return d.Sync()
+}
+
+// OpenStore loads the snapshot from `dir` (if there is one), replays the
+// write-ahead log on top of it and keeps logging every change to that log.
+func OpenStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s, err := loadSnapshot(filepath.Join(dir, snapshotFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	// A segment left by an interrupted compaction is older than the active log
+	if err := replayWAL(filepath.Join(dir, walCompactingFileName), s); err != nil {
+		return nil, err
+	}
+	walPath := filepath.Join(dir, walFileName)
+	if err := replayWAL(walPath, s); err != nil {
+		return nil, err
+	}
+	w, err := openWAL(walPath)
+	if err != nil {
+		return nil, err
+	}
+	s.dir = dir
+	s.attachWAL(w)
+	logger.Info("Store opened", "dir", dir, "collections", len(s.collections))
+	return s, nil
+}
+
+func (s *Store) attachWAL(w *wal) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.wal = w
+	for _, col := range s.collections {
+		col.mx.Lock()
+		col.wal = w
+		col.mx.Unlock()
+	}
+}
+
+// Close stops the reaper and the compactor and closes the write-ahead log of a
+// store created by `OpenStore`.
+func (s *Store) Close() error {
+	s.stopReaperLoop()
+	if s.wal == nil {
+		return nil
+	}
+	s.stopCompactionLoop()
+	return s.wal.Close()
 }
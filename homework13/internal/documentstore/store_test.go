@@ -2,8 +2,11 @@ package documentstore
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -18,15 +21,15 @@ func TestCreateCollection(t *testing.T) {
 	store := NewStore()
 
 	cfg := &CollectionConfig{PrimaryKey: "id"}
-	created, col := store.CreateCollection("test_collection", cfg)
+	col, err := store.CreateCollection("test_collection", cfg)
 
-	assert.True(t, created, "the collection should be created successfully")
+	assert.NoError(t, err, "the collection should be created successfully")
 	assert.NotNil(t, col, "the returned collection pointer should not be nil")
 	assert.Contains(t, store.collections, "test_collection", "the collection should exist in the store")
 
 	// Attempt to create a collection with the same name
-	created, col = store.CreateCollection("test_collection", cfg)
-	assert.False(t, created, "creating a collection with the same name should fail")
+	col, err = store.CreateCollection("test_collection", cfg)
+	assert.ErrorIs(t, err, ErrCollectionAlreadyExists, "creating a collection with the same name should fail")
 	assert.Nil(t, col, "the returned collection should be nil when creation fails")
 }
 
@@ -36,13 +39,13 @@ func TestGetCollection(t *testing.T) {
 	cfg := &CollectionConfig{PrimaryKey: "id"}
 	store.CreateCollection("test_collection", cfg)
 
-	col, found := store.GetCollection("test_collection")
-	assert.True(t, found, "the collection should exist in the store")
+	col, err := store.GetCollection("test_collection")
+	assert.NoError(t, err, "the collection should exist in the store")
 	assert.NotNil(t, col, "the returned collection pointer should not be nil")
 
 	// Test retrieval of a non-existent collection
-	col, found = store.GetCollection("non_existent")
-	assert.False(t, found, "the collection should not exist in the store")
+	col, err = store.GetCollection("non_existent")
+	assert.ErrorIs(t, err, ErrCollectionNotFound, "the collection should not exist in the store")
 	assert.Nil(t, col, "the returned value for a non-existent collection should be nil")
 }
 
@@ -52,13 +55,42 @@ func TestDeleteCollection(t *testing.T) {
 	cfg := &CollectionConfig{PrimaryKey: "id"}
 	store.CreateCollection("test_collection", cfg)
 
-	deleted := store.DeleteCollection("test_collection")
-	assert.True(t, deleted, "the collection should be deleted successfully")
+	err := store.DeleteCollection("test_collection")
+	assert.NoError(t, err, "the collection should be deleted successfully")
 	assert.NotContains(t, store.collections, "test_collection", "the collection should no longer exist in the store")
 
 	// Test attempting to delete a non-existent collection
-	deleted = store.DeleteCollection("non_existent")
-	assert.False(t, deleted, "deleting a non-existent collection should return false")
+	err = store.DeleteCollection("non_existent")
+	assert.ErrorIs(t, err, ErrCollectionNotFound, "deleting a non-existent collection should return ErrCollectionNotFound")
+}
+
+func TestListCollections(t *testing.T) {
+	store := NewStore()
+	assert.Empty(t, store.ListCollections(), "a new store should have no collections")
+
+	store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	store.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	store.CreateCollection("events", &CollectionConfig{PrimaryKey: "id"})
+	store.DeleteCollection("events")
+	assert.Equal(t, []string{"orders", "users"}, store.ListCollections(), "names should be sorted and skip deleted collections")
+}
+
+func TestCollectionInfo(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("test_collection", &CollectionConfig{PrimaryKey: "id", TTL: time.Hour})
+	col.Put(testDocument("key1", "val1"))
+	col.Put(testDocument("key2", "val2"))
+	col.CreateIndex("val")
+	col.CreateUniqueIndex("id", "val")
+
+	expected := CollectionInfo{
+		Name:          "test_collection",
+		Config:        CollectionConfig{PrimaryKey: "id", TTL: time.Hour},
+		Documents:     2,
+		Indexes:       []string{"val"},
+		UniqueIndexes: []string{"id,val"},
+	}
+	assert.Equal(t, expected, col.Info(), "info should describe the collection")
 }
 
 func TestDumpAndNewStoreFromDump(t *testing.T) {
@@ -132,4 +164,63 @@ func TestMarshalJSONAndUnmarshalJSON(t *testing.T) {
 	// Verify the collections are the same in both stores
 	_, exists := newStore.collections["test_collection"]
 	assert.True(t, exists, "The collection 'test_collection' should exist in the unmarshalled store")
-}
\ No newline at end of file
+}
+func TestGetCollectionReturnsSharedCollection(t *testing.T) {
+	store := NewStore()
+	store.CreateCollection("test_collection", &CollectionConfig{PrimaryKey: "id"})
+
+	first, _ := store.GetCollection("test_collection")
+	second, _ := store.GetCollection("test_collection")
+	assert.Same(t, first, second, "every call should return the stored collection")
+
+	first.Put(testDocument("key1", "val1"))
+	first.CreateIndex("val")
+	_, err := second.Query("val", QueryParams{})
+	assert.NoError(t, err, "indexes created through one pointer should be visible through another")
+}
+
+func TestDeletedCollectionRejectsWrites(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("test_collection", &CollectionConfig{PrimaryKey: "id"})
+	assert.NoError(t, store.DeleteCollection("test_collection"))
+
+	_, err := col.Put(testDocument("key1", "val1"))
+	assert.ErrorIs(t, err, ErrCollectionNotFound)
+	assert.ErrorIs(t, col.CreateIndex("val"), ErrCollectionNotFound)
+
+	recreated, err := store.CreateCollection("test_collection", &CollectionConfig{PrimaryKey: "id"})
+	assert.NoError(t, err)
+	_, err = recreated.Put(testDocument("key1", "val1"))
+	assert.NoError(t, err, "a new collection with the same name should be usable")
+}
+
+func TestStoreConcurrentAccess(t *testing.T) {
+	store, err := OpenStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	store.CreateCollection("shared", &CollectionConfig{PrimaryKey: "id"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("collection%d", i)
+			for j := 0; j < 20; j++ {
+				store.CreateCollection(name, &CollectionConfig{PrimaryKey: "id"})
+				if col, err := store.GetCollection(name); err == nil {
+					col.Put(testDocument("key", "val"))
+				}
+				store.DeleteCollection(name)
+
+				shared, _ := store.GetCollection("shared")
+				shared.Put(testDocument(fmt.Sprintf("key%d-%d", i, j), "val"))
+				store.Dump()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	shared, _ := store.GetCollection("shared")
+	assert.Len(t, shared.List(), 8*20, "no write to the shared collection should be lost")
+}
@@ -0,0 +1,141 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// timeNow is replaced in tests.
+var timeNow = time.Now
+
+func (d Document) expired(now time.Time) bool {
+	return (d.ExpiresAt != nil && !now.Before(*d.ExpiresAt)) ||
+		(d.TTLExpiresAt != nil && !now.Before(*d.TTLExpiresAt))
+}
+
+// liveDoc has to be called with the collection's lock held. Expired documents
+// are treated as missing until the reaper removes them.
+func (s *Collection) liveDoc(key string) (Document, bool) {
+	doc, ok := s.docs[key]
+	if !ok || doc.expired(timeNow()) {
+		return Document{}, false
+	}
+	return doc, true
+}
+
+// applyTTL has to be called with the collection's lock held on every write.
+// Documents without their own expiry get the collection's TTL counted from
+// now, whatever TTLExpiresAt they came with.
+func (s *Collection) applyTTL(doc *Document) {
+	doc.TTLExpiresAt = nil
+	if doc.ExpiresAt == nil && s.config.TTL > 0 {
+		expiresAt := timeNow().Add(s.config.TTL)
+		doc.TTLExpiresAt = &expiresAt
+	}
+}
+
+// dropExpired has to be called with the collection's write lock held. It
+// removes the document if it has expired, so writes never see it.
+func (s *Collection) dropExpired(key string) error {
+	doc, ok := s.docs[key]
+	if !ok || !doc.expired(timeNow()) {
+		return nil
+	}
+	if s.wal != nil {
+		err := s.wal.append(walRecord{Op: walOpDelete, Collection: s.name, Key: key})
+		if err != nil {
+			logger.Error("Failed to log expiry", "collection", s.name, "key", key, "error", err)
+			return err
+		}
+	}
+	delete(s.docs, key)
+	s.updateIndex(key, &doc, nil)
+	s.notify(key, &doc, nil)
+	return nil
+}
+
+// ReapExpired removes expired documents and returns how many were removed.
+// Each removal is logged and reported to watchers like a delete.
+func (s *Collection) ReapExpired() (int, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.dropped {
+		return 0, nil
+	}
+	now := timeNow()
+	removed := 0
+	for key, doc := range s.docs {
+		if !doc.expired(now) {
+			continue
+		}
+		if err := s.dropExpired(key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// StartReaper runs a background goroutine that removes expired documents
+// from every collection each `interval`. It is stopped by `Close`.
+func (s *Store) StartReaper(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("reaper interval has to be positive, got %s", interval)
+	}
+	s.reaperMx.Lock()
+	defer s.reaperMx.Unlock()
+	if s.stopReaper != nil {
+		return errors.New("reaper is already running")
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	s.stopReaper = stop
+	s.reaperDone = done
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			s.reapExpired()
+		}
+	}()
+	logger.Info("Reaper started", "interval", interval)
+	return nil
+}
+
+func (s *Store) reapExpired() {
+	s.mx.RLock()
+	cols := make([]*Collection, 0, len(s.collections))
+	for _, col := range s.collections {
+		cols = append(cols, col)
+	}
+	s.mx.RUnlock()
+
+	for _, col := range cols {
+		removed, err := col.ReapExpired()
+		if err != nil {
+			logger.Error("Reaping expired documents failed", "collection", col.name, "error", err)
+		}
+		if removed > 0 {
+			logger.Info("Expired documents removed", "collection", col.name, "count", removed)
+		}
+	}
+}
+
+func (s *Store) stopReaperLoop() {
+	s.reaperMx.Lock()
+	defer s.reaperMx.Unlock()
+	if s.stopReaper == nil {
+		return
+	}
+	close(s.stopReaper)
+	<-s.reaperDone
+	s.stopReaper = nil
+}
@@ -0,0 +1,158 @@
+package documentstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock replaces timeNow for the duration of the test.
+func fakeClock(t *testing.T) *time.Time {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+	return &now
+}
+
+func TestCollectionTTL(t *testing.T) {
+	now := fakeClock(t)
+	store := NewStore()
+	col, _ := store.CreateCollection("sessions", &CollectionConfig{PrimaryKey: "id", TTL: time.Minute})
+	assert.NoError(t, col.CreateIndex("val"))
+
+	col.Put(testDocument("key1", "val1"))
+	*now = now.Add(30 * time.Second)
+	col.Put(testDocument("key2", "val2"))
+
+	*now = now.Add(40 * time.Second)
+	_, err := col.Get("key1")
+	assert.ErrorIs(t, err, ErrDocumentNotFound, "expired document should not be returned")
+	_, err = col.Get("key2")
+	assert.NoError(t, err, "document within its TTL should be returned")
+	assert.Len(t, col.List(), 1)
+	found, _ := col.Find(Exists("val"))
+	assert.Len(t, found, 1)
+	queried, _ := col.Query("val", QueryParams{})
+	assert.Len(t, queried, 1)
+	page, _ := col.ListPage(PageOptions{})
+	assert.Len(t, page.Documents, 1)
+
+	_, err = col.Update("key2", SetField("val", StringValue("touched")))
+	assert.NoError(t, err)
+	*now = now.Add(50 * time.Second)
+	_, err = col.Get("key2")
+	assert.NoError(t, err, "updates should restart the TTL")
+
+	rev, err := col.PutIf(testDocument("key1", "again"), 0)
+	assert.NoError(t, err, "expired document should count as missing")
+	assert.NotZero(t, rev)
+}
+
+func TestDocumentExpiresAt(t *testing.T) {
+	now := fakeClock(t)
+	store := NewStore()
+	col, _ := store.CreateCollection("test", &CollectionConfig{PrimaryKey: "id"})
+	assert.NoError(t, col.CreateUniqueIndex("val"))
+
+	doc := testDocument("key1", "val1")
+	expiresAt := now.Add(time.Second)
+	doc.ExpiresAt = &expiresAt
+	col.Put(doc)
+	col.Put(testDocument("key2", "val2"))
+
+	*now = now.Add(time.Second)
+	_, err := col.Get("key1")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+	_, err = col.Get("key2")
+	assert.NoError(t, err, "documents without expiry should be kept")
+	_, err = col.Put(testDocument("key3", "val1"))
+	assert.NoError(t, err, "expired documents should not hold unique values")
+}
+
+func TestWritesRestartTTL(t *testing.T) {
+	now := fakeClock(t)
+	store := NewStore()
+	col, _ := store.CreateCollection("sessions", &CollectionConfig{PrimaryKey: "id", TTL: time.Minute})
+	col.Put(testDocument("key1", "val1"))
+	col.Put(testDocument("key2", "val2"))
+
+	*now = now.Add(40 * time.Second)
+	doc, err := col.Get("key1")
+	assert.NoError(t, err)
+	_, err = col.Put(*doc)
+	assert.NoError(t, err, "a document read back should be writable")
+	tx := store.Begin()
+	doc, err = tx.Get("sessions", "key2")
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Put("sessions", *doc))
+	assert.NoError(t, tx.Commit())
+
+	*now = now.Add(40 * time.Second)
+	_, err = col.Get("key1")
+	assert.NoError(t, err, "put should restart the TTL of a document read back")
+	_, err = col.Get("key2")
+	assert.NoError(t, err, "commit should restart the TTL")
+}
+
+func TestUpdateKeepsDocumentExpiresAt(t *testing.T) {
+	now := fakeClock(t)
+	store := NewStore()
+	col, _ := store.CreateCollection("sessions", &CollectionConfig{PrimaryKey: "id", TTL: time.Minute})
+	doc := testDocument("key1", "val1")
+	expiresAt := now.Add(time.Hour)
+	doc.ExpiresAt = &expiresAt
+	col.Put(doc)
+
+	updated, err := col.Update("key1", SetField("val", StringValue("touched")))
+	assert.NoError(t, err)
+	assert.Equal(t, expiresAt, *updated.ExpiresAt, "update should keep the document's own expiry")
+	*now = now.Add(30 * time.Minute)
+	_, err = col.Get("key1")
+	assert.NoError(t, err, "the collection's TTL should not apply to documents with their own expiry")
+}
+
+func TestReapExpired(t *testing.T) {
+	now := fakeClock(t)
+	dir := t.TempDir()
+	store, err := OpenStore(dir)
+	assert.NoError(t, err)
+	col, _ := store.CreateCollection("sessions", &CollectionConfig{PrimaryKey: "id", TTL: time.Minute})
+	col.Put(testDocument("key1", "val1"))
+	col.Put(testDocument("key2", "val2"))
+	w, _ := col.Watch(nil, 0)
+	defer w.Close()
+
+	*now = now.Add(time.Minute)
+	col.Put(testDocument("key3", "val3"))
+	removed, err := col.ReapExpired()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.Len(t, col.docs, 1, "expired documents should be removed from storage")
+	<-w.Events()
+	assert.Equal(t, ChangeTypeDelete, (<-w.Events()).Type, "expiry should be reported as a delete")
+	assert.NoError(t, store.Close())
+
+	timeNow = time.Now
+	restored, err := OpenStore(dir)
+	assert.NoError(t, err)
+	defer restored.Close()
+	col, _ = restored.GetCollection("sessions")
+	assert.Len(t, col.docs, 1, "expiry deletes should be replayed from the wal")
+}
+
+func TestStartReaper(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+	col, _ := store.CreateCollection("sessions", &CollectionConfig{PrimaryKey: "id", TTL: time.Millisecond})
+	col.Put(testDocument("key1", "val1"))
+
+	assert.Error(t, store.StartReaper(0))
+	assert.NoError(t, store.StartReaper(5*time.Millisecond))
+	assert.Error(t, store.StartReaper(5*time.Millisecond), "reaper should only be started once")
+	assert.Eventually(t, func() bool {
+		col.mx.RLock()
+		defer col.mx.RUnlock()
+		return len(col.docs) == 0
+	}, time.Second, 5*time.Millisecond, "reaper should remove expired documents")
+}
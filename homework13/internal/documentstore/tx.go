@@ -0,0 +1,302 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Tx buffers puts and deletes across collections until `Commit`. Reads see
+// the transaction's own writes. Documents read through the transaction must
+// not change before the commit, otherwise it fails with a *RevisionConflictError.
+type Tx struct {
+	store  *Store
+	writes map[string]map[string]*Document // Nil marks a delete
+	reads  map[string]map[string]uint64    // Revisions seen, 0 for missing documents
+	done   bool
+}
+
+// Begin starts a transaction. It has to be finished with `Commit` or `Rollback`.
+func (s *Store) Begin() *Tx {
+	return &Tx{
+		store:  s,
+		writes: make(map[string]map[string]*Document),
+		reads:  make(map[string]map[string]uint64),
+	}
+}
+
+// Tx runs fn in a transaction and commits it if fn returns nil. Otherwise
+// nothing fn wrote is applied and its error is returned.
+func (s *Store) Tx(fn func(tx *Tx) error) error {
+	tx := s.Begin()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Get returns ErrDocumentNotFound if there is no such document, including
+// documents deleted earlier in the transaction.
+func (tx *Tx) Get(collection string, key string) (*Document, error) {
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	if doc, ok := tx.writes[collection][key]; ok {
+		if doc == nil {
+			return nil, fmt.Errorf("%w: %s", ErrDocumentNotFound, key)
+		}
+		copied := doc.Clone()
+		return &copied, nil
+	}
+	col, err := tx.store.GetCollection(collection)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := col.Get(key)
+	if err != nil && !errors.Is(err, ErrDocumentNotFound) {
+		return nil, err
+	}
+	var revision uint64
+	if doc != nil {
+		revision = doc.Revision
+	}
+	tx.read(collection, key, revision)
+	return doc, err
+}
+
+// read remembers the first revision seen for the key.
+func (tx *Tx) read(collection string, key string, revision uint64) {
+	if tx.reads[collection] == nil {
+		tx.reads[collection] = make(map[string]uint64)
+	}
+	if _, ok := tx.reads[collection][key]; !ok {
+		tx.reads[collection][key] = revision
+	}
+}
+
+func (tx *Tx) write(collection string, key string, doc *Document) {
+	if tx.writes[collection] == nil {
+		tx.writes[collection] = make(map[string]*Document)
+	}
+	tx.writes[collection][key] = doc
+}
+
+// Put validates the primary key right away, unique indexes are only checked on commit.
+func (tx *Tx) Put(collection string, doc Document) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	col, err := tx.store.GetCollection(collection)
+	if err != nil {
+		return err
+	}
+	key, err := col.primaryKey(doc)
+	if err != nil {
+		return err
+	}
+	doc = doc.Clone()
+	tx.write(collection, key, &doc)
+	return nil
+}
+
+// PutIf works like `Put`, but fails with a *RevisionConflictError unless the
+// document's revision is `revision` (0 for a missing document). The revision
+// is checked when called, the commit fails if it changes later.
+func (tx *Tx) PutIf(collection string, doc Document, revision uint64) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	col, err := tx.store.GetCollection(collection)
+	if err != nil {
+		return err
+	}
+	key, err := col.primaryKey(doc)
+	if err != nil {
+		return err
+	}
+	if err := tx.checkRevision(collection, key, revision); err != nil {
+		return err
+	}
+	doc = doc.Clone()
+	tx.write(collection, key, &doc)
+	return nil
+}
+
+// Delete returns ErrDocumentNotFound if there is no such document.
+func (tx *Tx) Delete(collection string, key string) error {
+	if _, err := tx.Get(collection, key); err != nil {
+		return err
+	}
+	tx.write(collection, key, nil)
+	return nil
+}
+
+// DeleteIf works like `Delete`, with the revision checked as in `PutIf`.
+func (tx *Tx) DeleteIf(collection string, key string, revision uint64) error {
+	if err := tx.checkRevision(collection, key, revision); err != nil {
+		return err
+	}
+	return tx.Delete(collection, key)
+}
+
+func (tx *Tx) checkRevision(collection string, key string, revision uint64) error {
+	doc, err := tx.Get(collection, key)
+	if err != nil && !errors.Is(err, ErrDocumentNotFound) {
+		return err
+	}
+	if err != nil {
+		return checkRevision(key, Document{}, false, &revision)
+	}
+	return checkRevision(key, *doc, true, &revision)
+}
+
+// Rollback drops the buffered writes. It is a no-op for finished transactions.
+func (tx *Tx) Rollback() {
+	tx.done = true
+	tx.writes = nil
+	tx.reads = nil
+}
+
+// txUndo restores a document changed during a failed commit.
+type txUndo struct {
+	col *Collection
+	key string
+	old Document
+	had bool
+}
+
+// Commit applies all writes or none of them. It fails with a
+// *RevisionConflictError if a document read by the transaction has changed,
+// with a *UniqueConstraintError like `Put` does, or with ErrCollectionNotFound
+// if a collection was deleted in the meantime.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	defer tx.Rollback()
+
+	names := make([]string, 0, len(tx.writes)+len(tx.reads))
+	for name := range tx.writes {
+		names = append(names, name)
+	}
+	for name := range tx.reads {
+		if _, ok := tx.writes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	// All lookups happen before any collection is locked: DeleteCollection
+	// holds the store lock while it takes a collection lock, so asking for the
+	// store lock with a collection locked could deadlock against it
+	cols := make(map[string]*Collection, len(names))
+	for _, name := range names {
+		col, err := tx.store.GetCollection(name)
+		if err != nil {
+			return err
+		}
+		cols[name] = col
+	}
+	// Collections are always locked in the same order so commits can't deadlock
+	sort.Strings(names)
+	for _, name := range names {
+		col := cols[name]
+		col.mx.Lock()
+		defer col.mx.Unlock()
+		if err := col.checkDropped(); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range names {
+		for key, revision := range tx.reads[name] {
+			doc, found := cols[name].liveDoc(key)
+			if err := checkRevision(key, doc, found, &revision); err != nil {
+				return err
+			}
+		}
+	}
+
+	var undo []txUndo
+	var ops []walRecord
+	rollback := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			u := undo[i]
+			current := u.col.docs[u.key]
+			if u.had {
+				u.col.docs[u.key] = u.old
+				u.col.updateIndex(u.key, &current, &u.old)
+			} else {
+				delete(u.col.docs, u.key)
+				u.col.updateIndex(u.key, &current, nil)
+			}
+		}
+	}
+	for _, name := range names {
+		col := cols[name]
+		keys := make([]string, 0, len(tx.writes[name]))
+		for key := range tx.writes[name] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			doc := tx.writes[name][key]
+			if err := col.dropExpired(key); err != nil {
+				rollback()
+				return err
+			}
+			old, had := col.docs[key]
+			if doc == nil {
+				if !had {
+					// Only put earlier in the transaction, reads were checked above
+					continue
+				}
+				delete(col.docs, key)
+				col.updateIndex(key, &old, nil)
+				undo = append(undo, txUndo{col: col, key: key, old: old, had: had})
+				ops = append(ops, walRecord{Op: walOpDelete, Collection: name, Key: key})
+				continue
+			}
+			stored := *doc
+			col.applyTTL(&stored)
+			stored.Revision = col.nextRevision()
+			col.docs[key] = stored
+			if had {
+				col.updateIndex(key, &old, &stored)
+			} else {
+				col.updateIndex(key, nil, &stored)
+			}
+			undo = append(undo, txUndo{col: col, key: key, old: old, had: had})
+			ops = append(ops, walRecord{Op: walOpPut, Collection: name, Key: key, Doc: &stored})
+		}
+	}
+	// Unique indexes are checked once every write is in place, so documents
+	// can swap values within a transaction but can't end up sharing one
+	for _, u := range undo {
+		if doc, ok := u.col.docs[u.key]; ok {
+			if err := u.col.checkUniqueIndexes(u.key, doc); err != nil {
+				rollback()
+				return err
+			}
+		}
+	}
+
+	if tx.store.wal != nil && len(ops) > 0 {
+		if err := tx.store.wal.append(walRecord{Op: walOpTx, Ops: ops}); err != nil {
+			logger.Error("Failed to log transaction", "error", err)
+			rollback()
+			return err
+		}
+	}
+	// Watchers only hear about committed writes
+	for _, u := range undo {
+		var before, after *Document
+		if u.had {
+			before = &u.old
+		}
+		if doc, ok := u.col.docs[u.key]; ok {
+			after = &doc
+		}
+		u.col.notify(u.key, before, after)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package documentstore
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTxStore(t *testing.T, dir string) *Store {
+	store, err := OpenStore(dir)
+	assert.NoError(t, err)
+	for _, name := range []string{"users", "orders"} {
+		col, err := store.CreateCollection(name, &CollectionConfig{PrimaryKey: "id"})
+		assert.NoError(t, err)
+		_, err = col.Put(testDocument("key1", "val1"))
+		assert.NoError(t, err)
+	}
+	return store
+}
+
+func TestTxCommit(t *testing.T) {
+	dir := t.TempDir()
+	store := newTxStore(t, dir)
+
+	err := store.Tx(func(tx *Tx) error {
+		assert.NoError(t, tx.Put("users", testDocument("key2", "val2")))
+		doc, err := tx.Get("users", "key2")
+		assert.NoError(t, err, "transaction should read its own writes")
+		assert.Equal(t, "val2", doc.Fields["val"].Value)
+
+		assert.NoError(t, tx.Delete("orders", "key1"))
+		_, err = tx.Get("orders", "key1")
+		assert.ErrorIs(t, err, ErrDocumentNotFound, "transaction should not see its own deletes")
+
+		users, _ := store.GetCollection("users")
+		_, err = users.Get("key2")
+		assert.ErrorIs(t, err, ErrDocumentNotFound, "writes should not be visible before commit")
+		return nil
+	})
+	assert.NoError(t, err, "Tx should commit")
+	assert.NoError(t, store.Close())
+
+	restored, err := OpenStore(dir)
+	assert.NoError(t, err)
+	defer restored.Close()
+	users, _ := restored.GetCollection("users")
+	_, err = users.Get("key2")
+	assert.NoError(t, err, "committed put should be replayed")
+	orders, _ := restored.GetCollection("orders")
+	_, err = orders.Get("key1")
+	assert.ErrorIs(t, err, ErrDocumentNotFound, "committed delete should be replayed")
+}
+
+func TestTxRollback(t *testing.T) {
+	store := newTxStore(t, t.TempDir())
+	defer store.Close()
+
+	failure := errors.New("failure")
+	err := store.Tx(func(tx *Tx) error {
+		tx.Put("users", testDocument("key2", "val2"))
+		tx.Delete("orders", "key1")
+		return failure
+	})
+	assert.ErrorIs(t, err, failure, "error from the function should be returned")
+
+	users, _ := store.GetCollection("users")
+	_, err = users.Get("key2")
+	assert.ErrorIs(t, err, ErrDocumentNotFound, "rolled back put should not be applied")
+	orders, _ := store.GetCollection("orders")
+	_, err = orders.Get("key1")
+	assert.NoError(t, err, "rolled back delete should not be applied")
+
+	tx := store.Begin()
+	tx.Rollback()
+	assert.ErrorIs(t, tx.Commit(), ErrTxDone)
+	assert.ErrorIs(t, tx.Put("users", testDocument("key3", "val3")), ErrTxDone)
+}
+
+func TestTxAllOrNothing(t *testing.T) {
+	store := newTxStore(t, t.TempDir())
+	defer store.Close()
+	orders, _ := store.GetCollection("orders")
+	assert.NoError(t, orders.CreateUniqueIndex("val"))
+
+	err := store.Tx(func(tx *Tx) error {
+		tx.Put("users", testDocument("key2", "val2"))
+		tx.Put("orders", testDocument("key2", "val9"))
+		tx.Put("orders", testDocument("key3", "val9"))
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrUniqueConstraint, "conflicting writes should fail the commit")
+
+	users, _ := store.GetCollection("users")
+	_, err = users.Get("key2")
+	assert.ErrorIs(t, err, ErrDocumentNotFound, "writes to other collections should be undone")
+	_, err = orders.Get("key2")
+	assert.ErrorIs(t, err, ErrDocumentNotFound, "earlier writes to the same collection should be undone")
+	docs, err := orders.Query("val", QueryParams{})
+	assert.NoError(t, err)
+	assert.Len(t, docs, 1, "index should be restored")
+}
+
+func TestTxSwapsUniqueValues(t *testing.T) {
+	dir := t.TempDir()
+	store := newTxStore(t, dir)
+	orders, _ := store.GetCollection("orders")
+	assert.NoError(t, orders.CreateUniqueIndex("val"))
+	orders.Put(testDocument("key2", "val2"))
+
+	err := store.Tx(func(tx *Tx) error {
+		tx.Put("orders", testDocument("key1", "val2"))
+		tx.Put("orders", testDocument("key2", "val1"))
+		return nil
+	})
+	assert.NoError(t, err, "documents should be able to swap unique values")
+	doc, _ := orders.Get("key1")
+	assert.Equal(t, "val2", doc.Fields["val"].Value)
+	doc, _ = orders.Get("key2")
+	assert.Equal(t, "val1", doc.Fields["val"].Value)
+	assert.NoError(t, store.Close())
+
+	restored, err := OpenStore(dir)
+	assert.NoError(t, err)
+	defer restored.Close()
+	orders, _ = restored.GetCollection("orders")
+	doc, err = orders.Get("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "val2", doc.Fields["val"].Value, "the swap should be replayed from the wal")
+	_, err = orders.Put(testDocument("key3", "val1"))
+	assert.ErrorIs(t, err, ErrUniqueConstraint, "the index should hold the swapped values")
+}
+
+func TestTxConflict(t *testing.T) {
+	store := newTxStore(t, t.TempDir())
+	defer store.Close()
+	users, _ := store.GetCollection("users")
+
+	tx := store.Begin()
+	_, err := tx.Get("users", "key1")
+	assert.NoError(t, err)
+	_, err = tx.Get("users", "missing")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+	tx.Put("users", testDocument("key1", "from tx"))
+
+	_, err = users.Put(testDocument("missing", "concurrent"))
+	assert.NoError(t, err)
+	err = tx.Commit()
+	assert.ErrorIs(t, err, ErrRevisionConflict, "documents read by the transaction should not change before commit")
+	stored, _ := users.Get("key1")
+	assert.Equal(t, "val1", stored.Fields["val"].Value)
+}
+
+func TestTxPutIf(t *testing.T) {
+	store := newTxStore(t, t.TempDir())
+	defer store.Close()
+	users, _ := store.GetCollection("users")
+	doc, _ := users.Get("key1")
+
+	tx := store.Begin()
+	err := tx.PutIf("users", Document{Fields: map[string]DocumentField{}}, 0)
+	assert.ErrorIs(t, err, ErrMissingPrimaryKey, "the primary key should be checked first")
+	err = tx.PutIf("users", Document{Fields: map[string]DocumentField{"id": {Type: DocumentFieldTypeNumber, Value: 1.0}}}, 0)
+	assert.ErrorIs(t, err, ErrPrimaryKeyNotString)
+	assert.ErrorIs(t, tx.PutIf("users", testDocument("key1", "val2"), doc.Revision+1), ErrRevisionConflict)
+	assert.ErrorIs(t, tx.DeleteIf("users", "key2", 1), ErrRevisionConflict)
+	assert.NoError(t, tx.PutIf("users", testDocument("key1", "val2"), doc.Revision))
+	assert.NoError(t, tx.PutIf("users", testDocument("key2", "val2"), 0))
+	assert.NoError(t, tx.Commit())
+
+	stored, _ := users.Get("key1")
+	assert.Equal(t, "val2", stored.Fields["val"].Value)
+}
+
+func TestTxCommitDoesNotDeadlockWithDeleteCollection(t *testing.T) {
+	store := NewStore()
+	store.CreateCollection("a", &CollectionConfig{PrimaryKey: "id"})
+	store.CreateCollection("b", &CollectionConfig{PrimaryKey: "id"})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 2000; i++ {
+			tx := store.Begin()
+			tx.Put("a", testDocument("key1", "val1"))
+			tx.Put("b", testDocument("key1", "val1"))
+			// Fails whenever "a" is gone, only returning matters here
+			tx.Commit()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 2000; i++ {
+			store.DeleteCollection("a")
+			store.CreateCollection("a", &CollectionConfig{PrimaryKey: "id"})
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Commit and DeleteCollection deadlocked")
+	}
+}
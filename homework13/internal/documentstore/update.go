@@ -0,0 +1,288 @@
+package documentstore
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type UpdateOpType string
+
+const (
+	UpdateOpSet    UpdateOpType = "set"
+	UpdateOpUnset  UpdateOpType = "unset"
+	UpdateOpInc    UpdateOpType = "inc"
+	UpdateOpAppend UpdateOpType = "append"
+	UpdateOpMerge  UpdateOpType = "merge"
+)
+
+// UpdateOp changes one field of a document. Path addresses nested objects
+// with dots the same way filters do, missing parent objects are created.
+type UpdateOp struct {
+	Op    UpdateOpType   `json:"op"`
+	Path  string         `json:"path"`
+	Value *DocumentField `json:"value,omitempty"`
+}
+
+func SetField(path string, value *DocumentField) UpdateOp {
+	return UpdateOp{Op: UpdateOpSet, Path: path, Value: value}
+}
+
+func UnsetField(path string) UpdateOp {
+	return UpdateOp{Op: UpdateOpUnset, Path: path}
+}
+
+// IncField adds `delta` to a number field, a missing field counts as 0.
+func IncField(path string, delta float64) UpdateOp {
+	return UpdateOp{Op: UpdateOpInc, Path: path, Value: NumberValue(delta)}
+}
+
+// AppendField adds the value to the end of an array field, a missing field
+// counts as an empty array.
+func AppendField(path string, value *DocumentField) UpdateOp {
+	return UpdateOp{Op: UpdateOpAppend, Path: path, Value: value}
+}
+
+// MergeField copies the keys of an object value into an object field,
+// overwriting the ones it already has.
+func MergeField(path string, value *DocumentField) UpdateOp {
+	return UpdateOp{Op: UpdateOpMerge, Path: path, Value: value}
+}
+
+func (op UpdateOp) validate(primaryKey string) error {
+	parts := strings.Split(op.Path, ".")
+	for _, part := range parts {
+		if part == "" {
+			return fmt.Errorf("%w: bad path %q", ErrInvalidUpdate, op.Path)
+		}
+	}
+	if parts[0] == primaryKey {
+		return fmt.Errorf("%w: primary key %s can't be updated", ErrInvalidUpdate, primaryKey)
+	}
+	switch op.Op {
+	case UpdateOpUnset:
+		return nil
+	case UpdateOpSet, UpdateOpAppend:
+	case UpdateOpInc:
+		if op.Value != nil {
+			if _, ok := toFloat64(op.Value.Value); !ok || op.Value.Type != DocumentFieldTypeNumber {
+				return fmt.Errorf("%w: %s needs a number", ErrInvalidUpdate, op.Op)
+			}
+		}
+	case UpdateOpMerge:
+		if op.Value != nil && op.Value.Type != DocumentFieldTypeObject {
+			return fmt.Errorf("%w: %s needs an object", ErrInvalidUpdate, op.Op)
+		}
+	default:
+		return fmt.Errorf("%w: unknown operator %q", ErrInvalidUpdate, op.Op)
+	}
+	if op.Value == nil {
+		return fmt.Errorf("%w: %s needs a value", ErrInvalidUpdate, op.Op)
+	}
+	return nil
+}
+
+// Update applies the operations to the document with this key atomically and
+// returns the result. Either all operations are applied or none. It returns
+// ErrDocumentNotFound if there is no such document, ErrInvalidUpdate if an
+// operation can't be applied, and a *UniqueConstraintError like `Put` does.
+func (s *Collection) Update(key string, ops ...UpdateOp) (*Document, error) {
+	for _, op := range ops {
+		if err := op.validate(s.config.PrimaryKey); err != nil {
+			return nil, err
+		}
+	}
+	s.mx.Lock()
+	defer func() {
+		s.mx.Unlock()
+	}()
+	if err := s.checkDropped(); err != nil {
+		return nil, err
+	}
+	if err := s.dropExpired(key); err != nil {
+		return nil, err
+	}
+	old, ok := s.docs[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDocumentNotFound, key)
+	}
+
+	// Stored maps are shared with readers, so changes are made on copies
+	doc := Document{Fields: old.Fields, ExpiresAt: old.ExpiresAt}
+	for _, op := range ops {
+		if _, found := resolvePath(doc, op.Path); op.Op == UpdateOpUnset && !found {
+			continue
+		}
+		fields, err := updateObject(doc.Fields, strings.Split(op.Path, "."), op.apply)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s %s: %s", ErrInvalidUpdate, op.Op, op.Path, err)
+		}
+		doc.Fields = fields.(map[string]DocumentField)
+	}
+
+	if err := s.checkUniqueIndexes(key, doc); err != nil {
+		return nil, err
+	}
+	s.applyTTL(&doc)
+	doc.Revision = s.nextRevision()
+	if s.wal != nil {
+		// The whole document is logged so replay doesn't depend on the previous state
+		err := s.wal.append(walRecord{Op: walOpPut, Collection: s.name, Key: key, Doc: &doc})
+		if err != nil {
+			logger.Error("Failed to log update", "collection", s.name, "key", key, "error", err)
+			return nil, err
+		}
+	}
+	s.docs[key] = doc
+	s.updateIndex(key, &old, &doc)
+	s.notify(key, &old, &doc)
+	result := doc.Clone()
+	return &result, nil
+}
+
+// apply returns the new value of the field, or false if it has to be removed.
+func (op UpdateOp) apply(field DocumentField, found bool) (DocumentField, bool, error) {
+	switch op.Op {
+	case UpdateOpSet:
+		return op.Value.Clone(), true, nil
+	case UpdateOpUnset:
+		return DocumentField{}, false, nil
+	case UpdateOpInc:
+		delta, _ := toFloat64(op.Value.Value) // Checked by validate
+		if !found {
+			return DocumentField{Type: DocumentFieldTypeNumber, Value: delta}, true, nil
+		}
+		current, ok := toFloat64(field.Value)
+		if field.Type != DocumentFieldTypeNumber || !ok {
+			return field, false, fmt.Errorf("field is %s, not a number", field.Type)
+		}
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: current + delta}, true, nil
+	case UpdateOpAppend:
+		var items []interface{}
+		if found {
+			v := reflect.ValueOf(field.Value)
+			if field.Type != DocumentFieldTypeArray || v.Kind() != reflect.Slice {
+				return field, false, fmt.Errorf("field is %s, not an array", field.Type)
+			}
+			items = make([]interface{}, v.Len(), v.Len()+1)
+			for i := range items {
+				items[i] = v.Index(i).Interface()
+			}
+		}
+		return DocumentField{Type: DocumentFieldTypeArray, Value: append(items, cloneValue(op.Value.Value))}, true, nil
+	case UpdateOpMerge:
+		current := interface{}(map[string]interface{}{})
+		if found {
+			if field.Type != DocumentFieldTypeObject {
+				return field, false, fmt.Errorf("field is %s, not an object", field.Type)
+			}
+			current = field.Value
+		}
+		merged, err := mergeObjects(current, cloneValue(op.Value.Value))
+		return DocumentField{Type: DocumentFieldTypeObject, Value: merged}, true, err
+	}
+	return field, false, fmt.Errorf("unknown operator %q", op.Op)
+}
+
+// updateObject returns a copy of `obj` with `fn` applied to the value at
+// `parts`. Objects on the way are copied too, everything else is shared.
+func updateObject(obj interface{}, parts []string, fn func(DocumentField, bool) (DocumentField, bool, error)) (interface{}, error) {
+	part := parts[0]
+	switch m := obj.(type) {
+	case map[string]DocumentField:
+		result := make(map[string]DocumentField, len(m)+1)
+		for k, v := range m {
+			result[k] = v
+		}
+		current, found := m[part]
+		if len(parts) > 1 {
+			if !found {
+				current = DocumentField{Type: DocumentFieldTypeObject, Value: map[string]interface{}{}}
+			} else if current.Type != DocumentFieldTypeObject {
+				return nil, fmt.Errorf("%s is %s, not an object", part, current.Type)
+			}
+			child, err := updateObject(current.Value, parts[1:], fn)
+			if err != nil {
+				return nil, err
+			}
+			result[part] = DocumentField{Type: DocumentFieldTypeObject, Value: child}
+			return result, nil
+		}
+		field, keep, err := fn(current, found)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			result[part] = field
+		} else {
+			delete(result, part)
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(m)+1)
+		for k, v := range m {
+			result[k] = v
+		}
+		value, found := m[part]
+		if len(parts) > 1 {
+			if !found {
+				value = map[string]interface{}{}
+			} else if f := fieldFromValue(value); f.Type != DocumentFieldTypeObject {
+				return nil, fmt.Errorf("%s is %s, not an object", part, f.Type)
+			}
+			child, err := updateObject(value, parts[1:], fn)
+			if err != nil {
+				return nil, err
+			}
+			result[part] = child
+			return result, nil
+		}
+		field, keep, err := fn(fieldFromValue(value), found)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			result[part] = field.Value
+		} else {
+			delete(result, part)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("unsupported object value %T", obj)
+}
+
+func mergeObjects(dst, src interface{}) (interface{}, error) {
+	var entries map[string]DocumentField
+	switch m := src.(type) {
+	case map[string]DocumentField:
+		entries = m
+	case map[string]interface{}:
+		entries = make(map[string]DocumentField, len(m))
+		for k, v := range m {
+			entries[k] = fieldFromValue(v)
+		}
+	default:
+		return nil, fmt.Errorf("value is not an object")
+	}
+	switch m := dst.(type) {
+	case map[string]DocumentField:
+		result := make(map[string]DocumentField, len(m)+len(entries))
+		for k, v := range m {
+			result[k] = v
+		}
+		for k, v := range entries {
+			result[k] = v
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(m)+len(entries))
+		for k, v := range m {
+			result[k] = v
+		}
+		for k, v := range entries {
+			result[k] = v.Value
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("field is not an object")
+}
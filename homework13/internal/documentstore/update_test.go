@@ -0,0 +1,111 @@
+package documentstore
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdate(t *testing.T) {
+	col := newPeopleCollection(t)
+	before, _ := col.Get("key0")
+
+	doc, err := col.Update("key0",
+		SetField("name", StringValue("Olenka")),
+		UnsetField("active"),
+		UnsetField("missing.field"),
+		IncField("age", 2),
+		IncField("visits", 1),
+		AppendField("tags", StringValue("admin")),
+		SetField("address.geo.lat", NumberValue(50.45)),
+		MergeField("address", &DocumentField{Type: DocumentFieldTypeObject, Value: map[string]interface{}{"city": "Lviv", "street": "Shevchenka"}}),
+	)
+	assert.NoError(t, err, "Update should not return an error")
+	assert.Equal(t, Document{Fields: map[string]DocumentField{
+		"id":     {Type: DocumentFieldTypeString, Value: "key0"},
+		"name":   {Type: DocumentFieldTypeString, Value: "Olenka"},
+		"age":    {Type: DocumentFieldTypeNumber, Value: float64(32)},
+		"visits": {Type: DocumentFieldTypeNumber, Value: float64(1)},
+		"tags":   {Type: DocumentFieldTypeArray, Value: []interface{}{"admin"}},
+		"address": {Type: DocumentFieldTypeObject, Value: map[string]interface{}{
+			"city":   "Lviv",
+			"street": "Shevchenka",
+			"geo":    map[string]interface{}{"zip": float64(10000), "lat": 50.45},
+		}},
+	}, Revision: 6}, *doc, "update should bump the revision")
+
+	stored, _ := col.Get("key0")
+	assert.Equal(t, doc, stored, "updated document should be stored")
+	assert.Equal(t, "Olena", before.Fields["name"].Value, "documents read before the update should not change")
+	assert.Equal(t, "Kyiv", before.Fields["address"].Value.(map[string]interface{})["city"])
+	assert.Len(t, before.Fields["address"].Value.(map[string]interface{})["geo"], 1)
+}
+
+func TestUpdateErrors(t *testing.T) {
+	col := newPeopleCollection(t)
+	assert.NoError(t, col.CreateUniqueIndex("name"))
+
+	_, err := col.Update("nope", SetField("name", StringValue("x")))
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+
+	for _, op := range []UpdateOp{
+		SetField("id", StringValue("other")),
+		SetField("", StringValue("x")),
+		SetField("name", nil),
+		IncField("name", 1),
+		{Op: UpdateOpInc, Path: "age", Value: &DocumentField{Type: DocumentFieldTypeNumber, Value: "1"}},
+		AppendField("age", NumberValue(1)),
+		MergeField("name", &DocumentField{Type: DocumentFieldTypeObject, Value: map[string]interface{}{}}),
+		SetField("name.first", StringValue("x")),
+		{Op: "rename", Path: "name"},
+	} {
+		_, err := col.Update("key0", SetField("age", NumberValue(99)), op)
+		assert.ErrorIs(t, err, ErrInvalidUpdate, "operation %+v should be rejected", op)
+	}
+	doc, _ := col.Get("key0")
+	assert.Equal(t, float64(30), doc.Fields["age"].Value, "failed updates should not apply any operation")
+
+	_, err = col.Update("key0", SetField("name", StringValue("Marko")))
+	assert.ErrorIs(t, err, ErrUniqueConstraint)
+	_, err = col.Update("key0", SetField("name", StringValue("Olha")))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Olha"}, findNames(t, col, Eq("name", StringValue("Olha"))), "index should follow the update")
+}
+
+func TestUpdateConcurrentIncrements(t *testing.T) {
+	col := newPeopleCollection(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			col.Update("key1", IncField("age", 1))
+		}()
+	}
+	wg.Wait()
+	doc, _ := col.Get("key1")
+	assert.Equal(t, float64(75), doc.Fields["age"].Value, "no increment should be lost")
+}
+
+func TestUpdateIsReplayed(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	assert.NoError(t, err)
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(testDocument("key1", "val1"))
+	_, err = col.Update("key1", SetField("val", StringValue("val2")), IncField("n", 3))
+	assert.NoError(t, err)
+	assert.NoError(t, store.Close())
+
+	restored, err := OpenStore(dir)
+	assert.NoError(t, err)
+	defer restored.Close()
+	col, _ = restored.GetCollection("users")
+	doc, err := col.Get("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "val2", doc.Fields["val"].Value)
+	assert.Equal(t, float64(3), doc.Fields["n"].Value)
+}
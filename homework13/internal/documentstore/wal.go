@@ -0,0 +1,244 @@
+package documentstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+type walOp string
+
+const (
+	walOpPut              walOp = "put"
+	walOpDelete           walOp = "delete"
+	walOpCreateCollection walOp = "create_collection"
+	walOpDeleteCollection walOp = "delete_collection"
+	walOpCreateIndex      walOp = "create_index"
+	walOpDeleteIndex      walOp = "delete_index"
+	walOpTx               walOp = "tx"
+)
+
+// Every record on disk is an 8 byte header (payload length and CRC32 of the
+// payload, both little endian) followed by the JSON encoded walRecord.
+const walHeaderSize = 8
+
+// maxWALRecordSize caps the payload length, so a corrupted header can't make
+// replay allocate gigabytes. Larger records are refused on append.
+const maxWALRecordSize = 64 << 20
+
+var (
+	// errTornRecord is returned for a record cut short by the end of the file
+	errTornRecord = errors.New("torn wal record")
+	// errBadRecord is returned for a record that can't be used, it's only
+	// left by a crash if nothing follows it
+	errBadRecord = errors.New("bad wal record")
+)
+
+type walRecord struct {
+	Op         walOp             `json:"op"`
+	Collection string            `json:"collection"`
+	Key        string            `json:"key,omitempty"`
+	Field      string            `json:"field,omitempty"`
+	Unique     bool              `json:"unique,omitempty"`
+	Doc        *Document         `json:"doc,omitempty"`
+	Config     *CollectionConfig `json:"config,omitempty"`
+	Ops        []walRecord       `json:"ops,omitempty"` // Puts and deletes of a transaction
+}
+
+type wal struct {
+	file     *os.File
+	filename string
+	mx       sync.Mutex
+
+	// mutations counts records appended since the last rotation, `full` is
+	// signalled once it reaches `limit` so the compactor can kick in early
+	mutations atomic.Int64
+	limit     atomic.Int64
+	full      chan struct{}
+}
+
+func openWAL(filename string) (*wal, error) {
+	file, err := openWALFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &wal{file: file, filename: filename, full: make(chan struct{}, 1)}, nil
+}
+
+func openWALFile(filename string) (*os.File, error) {
+	return os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+}
+
+func (w *wal) append(rec walRecord) error {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("error marshalling wal record: %w", err)
+	}
+	if len(payload) > maxWALRecordSize {
+		return fmt.Errorf("%w: %d bytes", ErrRecordTooLarge, len(payload))
+	}
+	buf := make([]byte, walHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[walHeaderSize:], payload)
+
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if _, err := w.file.Write(buf); err != nil {
+		return fmt.Errorf("error writing wal record: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	n := w.mutations.Add(1)
+	if limit := w.limit.Load(); limit > 0 && n >= limit {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// rotate moves the current log to `segment` and starts an empty one, so the
+// moved segment can be folded into a snapshot while appends carry on.
+func (w *wal) rotate(segment string) error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	renameErr := os.Rename(w.filename, segment)
+	file, err := openWALFile(w.filename)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	if renameErr != nil {
+		return renameErr
+	}
+	w.mutations.Store(0)
+	return syncDir(filepath.Dir(w.filename))
+}
+
+func (w *wal) Close() error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	return w.file.Close()
+}
+
+// readWALRecord returns errTornRecord when the record is cut short and
+// errBadRecord when it's too large, its checksum doesn't match or it can't be
+// decoded. The returned length is the one the header claims in both cases.
+func readWALRecord(r io.Reader) (walRecord, int64, error) {
+	var rec walRecord
+	header := make([]byte, walHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF {
+			return rec, 0, io.EOF
+		}
+		return rec, walHeaderSize, errTornRecord
+	}
+	size := binary.LittleEndian.Uint32(header[0:4])
+	sum := binary.LittleEndian.Uint32(header[4:8])
+	n := int64(walHeaderSize) + int64(size)
+	if size > maxWALRecordSize {
+		return rec, n, errBadRecord
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return rec, n, errTornRecord
+	}
+	if crc32.ChecksumIEEE(payload) != sum {
+		return rec, n, errBadRecord
+	}
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return rec, n, errBadRecord
+	}
+	return rec, n, nil
+}
+
+// replayWAL applies all complete records from the file to the store. A crash
+// in the middle of an append can only damage the last record, so a bad record
+// running to the end of the file is cut off and new records are appended
+// right after the last good one. A bad record anywhere else returns
+// ErrCorruptWAL, as dropping it would lose the records after it.
+func replayWAL(filename string, s *Store) error {
+	file, err := os.OpenFile(filename, os.O_RDWR, 0o644)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	var applied int
+	for {
+		rec, n, err := readWALRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, errBadRecord) && offset+n < info.Size() {
+			return fmt.Errorf("%w: bad record in %s at offset %d", ErrCorruptWAL, filename, offset)
+		}
+		if err != nil {
+			logger.Warn("Torn wal record, truncating log", "filename", filename, "offset", offset)
+			if err := file.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+		s.applyWALRecord(rec)
+		offset += n
+		applied++
+	}
+	logger.Info("Wal replayed", "filename", filename, "records", applied)
+	return nil
+}
+
+func (s *Store) applyWALRecord(rec walRecord) {
+	switch rec.Op {
+	case walOpCreateCollection:
+		s.CreateCollection(rec.Collection, rec.Config)
+	case walOpDeleteCollection:
+		s.DeleteCollection(rec.Collection)
+	case walOpPut:
+		if col, err := s.GetCollection(rec.Collection); err == nil && rec.Doc != nil {
+			col.restore(*rec.Doc)
+		}
+	case walOpDelete:
+		if col, err := s.GetCollection(rec.Collection); err == nil {
+			col.Delete(rec.Key)
+		}
+	case walOpCreateIndex:
+		if col, err := s.GetCollection(rec.Collection); err == nil {
+			col.createIndex(indexFields(rec.Field), rec.Unique)
+		}
+	case walOpTx:
+		// A transaction is a single record, so it is either replayed whole or torn
+		for _, op := range rec.Ops {
+			s.applyWALRecord(op)
+		}
+	case walOpDeleteIndex:
+		if col, err := s.GetCollection(rec.Collection); err == nil {
+			col.DeleteIndex(indexFields(rec.Field)...)
+		}
+	default:
+		logger.Warn("Unknown wal record", "op", rec.Op)
+	}
+}
@@ -0,0 +1,174 @@
+package documentstore
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testDocument(key string, val string) Document {
+	return Document{
+		Fields: map[string]DocumentField{
+			"id":  {Type: DocumentFieldTypeString, Value: key},
+			"val": {Type: DocumentFieldTypeString, Value: val},
+		},
+	}
+}
+
+func TestOpenStoreReplaysWAL(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	assert.NoError(t, err, "OpenStore should not return an error")
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(testDocument("key1", "val1"))
+	col.Put(testDocument("key2", "val2"))
+	col.Put(testDocument("key1", "val3"))
+	col.Delete("key2")
+	store.CreateCollection("dropped", &CollectionConfig{PrimaryKey: "id"})
+	store.DeleteCollection("dropped")
+	assert.NoError(t, store.Close())
+
+	restored, err := OpenStore(dir)
+	assert.NoError(t, err, "OpenStore should replay the wal without errors")
+	defer restored.Close()
+
+	_, err = restored.GetCollection("dropped")
+	assert.ErrorIs(t, err, ErrCollectionNotFound, "deleted collection should not be restored")
+
+	col, err = restored.GetCollection("users")
+	assert.NoError(t, err, "collection should be restored from the wal")
+	doc, err := col.Get("key1")
+	assert.NoError(t, err, "document 'key1' should be restored")
+	assert.Equal(t, "val3", doc.Fields["val"].Value, "the latest put should win")
+	_, err = col.Get("key2")
+	assert.ErrorIs(t, err, ErrDocumentNotFound, "deleted document should not be restored")
+}
+
+func TestOpenStoreToleratesTornRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	assert.NoError(t, err)
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(testDocument("key1", "val1"))
+	col.Put(testDocument("key2", "val2"))
+	assert.NoError(t, store.Close())
+
+	// Simulate a crash in the middle of the last append
+	walPath := filepath.Join(dir, walFileName)
+	info, err := os.Stat(walPath)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Truncate(walPath, info.Size()-5))
+
+	restored, err := OpenStore(dir)
+	assert.NoError(t, err, "a torn final record should not prevent opening the store")
+	col, err = restored.GetCollection("users")
+	assert.NoError(t, err)
+	_, err = col.Get("key1")
+	assert.NoError(t, err, "complete records before the torn one should be applied")
+	_, err = col.Get("key2")
+	assert.ErrorIs(t, err, ErrDocumentNotFound, "the torn record should be skipped")
+
+	// New records must land after the last good one
+	col.Put(testDocument("key3", "val3"))
+	assert.NoError(t, restored.Close())
+
+	restored, err = OpenStore(dir)
+	assert.NoError(t, err)
+	defer restored.Close()
+	col, _ = restored.GetCollection("users")
+	_, err = col.Get("key3")
+	assert.NoError(t, err, "records written after recovery should be replayed")
+}
+
+func TestOpenStoreRejectsOversizedRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	assert.NoError(t, err)
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(testDocument("key1", "val1"))
+	assert.NoError(t, store.Close())
+
+	// A corrupted header claiming a 4GiB payload
+	walPath := filepath.Join(dir, walFileName)
+	file, err := os.OpenFile(walPath, os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.NoError(t, err)
+	header := make([]byte, walHeaderSize)
+	binary.LittleEndian.PutUint32(header[0:4], math.MaxUint32)
+	file.Write(append(header, []byte("garbage")...))
+	file.Close()
+
+	restored, err := OpenStore(dir)
+	assert.NoError(t, err, "an oversized record should be treated as torn")
+	col, err = restored.GetCollection("users")
+	assert.NoError(t, err)
+	_, err = col.Get("key1")
+	assert.NoError(t, err, "records before the corrupted one should be applied")
+	assert.NoError(t, restored.Close())
+}
+
+func TestOpenStoreRejectsCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	assert.NoError(t, err)
+	col, _ := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(testDocument("key1", "val1"))
+	col.Put(testDocument("key2", "val2"))
+	assert.NoError(t, store.Close())
+
+	walPath := filepath.Join(dir, walFileName)
+	data, err := os.ReadFile(walPath)
+	assert.NoError(t, err)
+
+	// A flipped byte in the last record looks like an interrupted append
+	last := append([]byte{}, data...)
+	last[len(last)-2] ^= 0xff
+	assert.NoError(t, os.WriteFile(walPath, last, 0o644))
+	restored, err := OpenStore(dir)
+	assert.NoError(t, err, "a bad final record should be treated as torn")
+	col, _ = restored.GetCollection("users")
+	_, err = col.Get("key1")
+	assert.NoError(t, err)
+	_, err = col.Get("key2")
+	assert.ErrorIs(t, err, ErrDocumentNotFound, "the bad final record should be skipped")
+	assert.NoError(t, restored.Close())
+
+	// The same in the first record would lose every record after it
+	first := append([]byte{}, data...)
+	first[walHeaderSize+1] ^= 0xff
+	assert.NoError(t, os.WriteFile(walPath, first, 0o644))
+	_, err = OpenStore(dir)
+	assert.ErrorIs(t, err, ErrCorruptWAL)
+	kept, err := os.ReadFile(walPath)
+	assert.NoError(t, err)
+	assert.Equal(t, first, kept, "a corrupt log should be left as it is")
+}
+
+func TestOpenStoreLoadsSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	snapshot := NewStore()
+	col, _ := snapshot.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(testDocument("key1", "val1"))
+	assert.NoError(t, snapshot.DumpToFile(filepath.Join(dir, snapshotFileName)))
+
+	store, err := OpenStore(dir)
+	assert.NoError(t, err)
+	col, _ = store.GetCollection("users")
+	col.Put(testDocument("key2", "val2"))
+	assert.NoError(t, store.Close())
+
+	restored, err := OpenStore(dir)
+	assert.NoError(t, err)
+	defer restored.Close()
+	col, err = restored.GetCollection("users")
+	assert.NoError(t, err)
+	assert.Len(t, col.List(), 2, "snapshot and wal documents should both be present")
+}
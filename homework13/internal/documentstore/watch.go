@@ -0,0 +1,134 @@
+package documentstore
+
+type ChangeType string
+
+const (
+	ChangeTypeInsert  ChangeType = "insert"
+	ChangeTypeReplace ChangeType = "replace"
+	ChangeTypeDelete  ChangeType = "delete"
+)
+
+// Events buffered for a watcher when Watch is called with buffer 0.
+const defaultWatchBuffer = 64
+
+// ChangeEvent describes one write. Before is nil for inserts and After is nil
+// for deletes. Revision is the one of After, or of Before for deletes.
+type ChangeEvent struct {
+	Type     ChangeType `json:"type"`
+	Key      string     `json:"key"`
+	Revision uint64     `json:"revision"`
+	Before   *Document  `json:"before,omitempty"`
+	After    *Document  `json:"after,omitempty"`
+}
+
+// Watcher receives change events of one collection until it is closed.
+type Watcher struct {
+	col    *Collection
+	filter *Filter
+	events chan ChangeEvent
+	err    error
+	closed bool
+}
+
+// Watch subscribes to changes of documents matching the filter before or
+// after the write, nil matches every document. Events are delivered in write
+// order. Writers never wait for watchers: once `buffer` events are pending
+// the watcher is closed with ErrWatcherOverflow.
+func (s *Collection) Watch(filter *Filter, buffer int) (*Watcher, error) {
+	if filter != nil {
+		if err := filter.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if buffer <= 0 {
+		buffer = defaultWatchBuffer
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if err := s.checkDropped(); err != nil {
+		return nil, err
+	}
+	w := &Watcher{col: s, filter: filter, events: make(chan ChangeEvent, buffer)}
+	if s.watchers == nil {
+		s.watchers = make(map[*Watcher]struct{})
+	}
+	s.watchers[w] = struct{}{}
+	return w, nil
+}
+
+// Events is closed when the watcher stops, `Err` tells why.
+func (w *Watcher) Events() <-chan ChangeEvent {
+	return w.events
+}
+
+// Err returns ErrWatcherOverflow or ErrCollectionNotFound once the events
+// channel is closed for that reason, and nil after `Close`.
+func (w *Watcher) Err() error {
+	w.col.mx.RLock()
+	defer w.col.mx.RUnlock()
+	return w.err
+}
+
+// Close unsubscribes the watcher, events still in the channel can be drained.
+func (w *Watcher) Close() {
+	w.col.mx.Lock()
+	defer w.col.mx.Unlock()
+	w.stop(nil)
+}
+
+// stop has to be called with the collection's lock held.
+func (w *Watcher) stop(err error) {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.err = err
+	close(w.events)
+	delete(w.col.watchers, w)
+}
+
+// notify has to be called with the collection's lock held, after the write is applied.
+func (s *Collection) notify(key string, before *Document, after *Document) {
+	if len(s.watchers) == 0 {
+		return
+	}
+	event := ChangeEvent{Key: key}
+	switch {
+	case before == nil:
+		event.Type = ChangeTypeInsert
+		event.Revision = after.Revision
+	case after == nil:
+		event.Type = ChangeTypeDelete
+		event.Revision = before.Revision
+	default:
+		event.Type = ChangeTypeReplace
+		event.Revision = after.Revision
+	}
+	for w := range s.watchers {
+		if w.filter != nil && !(before != nil && w.filter.Match(*before)) && !(after != nil && w.filter.Match(*after)) {
+			continue
+		}
+		// Every watcher gets its own copies
+		e := event
+		if before != nil {
+			doc := before.Clone()
+			e.Before = &doc
+		}
+		if after != nil {
+			doc := after.Clone()
+			e.After = &doc
+		}
+		select {
+		case w.events <- e:
+		default:
+			w.stop(ErrWatcherOverflow)
+		}
+	}
+}
+
+// stopWatchers has to be called with the collection's lock held.
+func (s *Collection) stopWatchers(err error) {
+	for w := range s.watchers {
+		w.stop(err)
+	}
+}
@@ -0,0 +1,124 @@
+package documentstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func nextEvent(t *testing.T, w *Watcher) ChangeEvent {
+	select {
+	case e, ok := <-w.Events():
+		assert.True(t, ok, "events channel should be open")
+		return e
+	default:
+		t.Fatal("expected a pending event")
+		return ChangeEvent{}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("test", &CollectionConfig{PrimaryKey: "id"})
+	w, err := col.Watch(nil, 0)
+	assert.NoError(t, err)
+
+	col.Put(testDocument("key1", "val1"))
+	col.Put(testDocument("key1", "val2"))
+	col.Update("key1", SetField("val", StringValue("val3")))
+	col.Delete("key1")
+
+	e := nextEvent(t, w)
+	assert.Equal(t, ChangeTypeInsert, e.Type)
+	assert.Nil(t, e.Before)
+	assert.Equal(t, "val1", e.After.Fields["val"].Value)
+	assert.Equal(t, e.After.Revision, e.Revision)
+
+	e = nextEvent(t, w)
+	assert.Equal(t, ChangeTypeReplace, e.Type)
+	assert.Equal(t, "val1", e.Before.Fields["val"].Value)
+	assert.Equal(t, "val2", e.After.Fields["val"].Value)
+
+	e = nextEvent(t, w)
+	assert.Equal(t, ChangeTypeReplace, e.Type, "update should be reported as a replace")
+	assert.Equal(t, "val3", e.After.Fields["val"].Value)
+
+	e = nextEvent(t, w)
+	assert.Equal(t, ChangeTypeDelete, e.Type)
+	assert.Equal(t, "key1", e.Key)
+	assert.Equal(t, "val3", e.Before.Fields["val"].Value)
+	assert.Nil(t, e.After)
+	assert.Equal(t, e.Before.Revision, e.Revision)
+
+	w.Close()
+	_, ok := <-w.Events()
+	assert.False(t, ok, "events channel should be closed")
+	assert.NoError(t, w.Err())
+	col.Put(testDocument("key2", "val"))
+}
+
+func TestWatchFilter(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("test", &CollectionConfig{PrimaryKey: "id"})
+	filter := Eq("val", StringValue("watched"))
+	w, err := col.Watch(&filter, 0)
+	assert.NoError(t, err)
+	defer w.Close()
+
+	col.Put(testDocument("key1", "other"))
+	col.Put(testDocument("key2", "watched"))
+	col.Put(testDocument("key2", "other"))
+	col.Put(testDocument("key2", "other again"))
+
+	assert.Len(t, w.Events(), 2, "only writes matching the filter before or after should be reported")
+	assert.Equal(t, ChangeTypeInsert, nextEvent(t, w).Type)
+	e := nextEvent(t, w)
+	assert.Equal(t, "other", e.After.Fields["val"].Value, "documents leaving the filter should be reported")
+
+	_, err = col.Watch(&Filter{Op: "bogus"}, 0)
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+}
+
+func TestWatchSlowConsumer(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("test", &CollectionConfig{PrimaryKey: "id"})
+	slow, _ := col.Watch(nil, 2)
+	fast, _ := col.Watch(nil, 10)
+	defer fast.Close()
+
+	for _, key := range []string{"key1", "key2", "key3"} {
+		_, err := col.Put(testDocument(key, "val"))
+		assert.NoError(t, err, "writers should not block on slow watchers")
+	}
+
+	assert.Len(t, slow.Events(), 2, "buffered events should still be delivered")
+	<-slow.Events()
+	<-slow.Events()
+	_, ok := <-slow.Events()
+	assert.False(t, ok, "overflowing watcher should be closed")
+	assert.ErrorIs(t, slow.Err(), ErrWatcherOverflow)
+	assert.Len(t, fast.Events(), 3, "other watchers should not be affected")
+}
+
+func TestWatchTxAndDrop(t *testing.T) {
+	store := NewStore()
+	col, _ := store.CreateCollection("test", &CollectionConfig{PrimaryKey: "id"})
+	col.Put(testDocument("key1", "val"))
+	w, _ := col.Watch(nil, 0)
+
+	assert.NoError(t, store.Tx(func(tx *Tx) error {
+		tx.Put("test", testDocument("key2", "val"))
+		tx.Delete("test", "key1")
+		assert.Len(t, w.Events(), 0, "uncommitted writes should not be reported")
+		return nil
+	}))
+	assert.Equal(t, ChangeTypeDelete, nextEvent(t, w).Type)
+	assert.Equal(t, ChangeTypeInsert, nextEvent(t, w).Type)
+
+	assert.NoError(t, store.DeleteCollection("test"))
+	_, ok := <-w.Events()
+	assert.False(t, ok, "watchers should be closed when the collection is deleted")
+	assert.ErrorIs(t, w.Err(), ErrCollectionNotFound)
+	_, err := col.Watch(nil, 0)
+	assert.ErrorIs(t, err, ErrCollectionNotFound)
+}
@@ -0,0 +1,87 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A frame on the wire is a big-endian uint32 length of the rest of the frame,
+// followed by the kind (1 byte), the command id (2 bytes), the request id
+// (4 bytes) and the payload.
+const headerSize = 1 + 2 + 4
+
+// MaxFrameSize limits the length prefix so a broken peer can't make us
+// allocate arbitrary amounts of memory.
+const MaxFrameSize = 16 << 20
+
+var (
+	ErrFrameTooLarge = errors.New("frame too large")
+	ErrInvalidFrame  = errors.New("invalid frame")
+)
+
+type FrameKind uint8
+
+const (
+	FrameRequest  FrameKind = iota + 1
+	FrameResponse           // Payload is the command's response JSON
+	FrameError              // Payload is the error JSON, see commands.ErrorPayload
+	FrameEvent              // Watch event, RequestID is the one of the `watch` request
+)
+
+type Frame struct {
+	Kind      FrameKind
+	Command   uint16 // Responses and events repeat the command of their request
+	RequestID uint32
+	Payload   []byte
+}
+
+// WriteFrame writes the frame with a single Write call, so frames written by
+// different goroutines under a lock never interleave.
+func WriteFrame(w io.Writer, f Frame) error {
+	if headerSize+len(f.Payload) > MaxFrameSize {
+		return fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, headerSize+len(f.Payload))
+	}
+	buf := make([]byte, 4+headerSize+len(f.Payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(headerSize+len(f.Payload)))
+	buf[4] = byte(f.Kind)
+	binary.BigEndian.PutUint16(buf[5:7], f.Command)
+	binary.BigEndian.PutUint32(buf[7:11], f.RequestID)
+	copy(buf[11:], f.Payload)
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadFrame returns io.EOF if the stream ends before a new frame starts and
+// io.ErrUnexpectedEOF if it ends in the middle of one.
+func ReadFrame(r io.Reader) (Frame, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return Frame{}, err
+	}
+	length := binary.BigEndian.Uint32(prefix[:])
+	if length > MaxFrameSize {
+		return Frame{}, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, length)
+	}
+	if length < headerSize {
+		return Frame{}, fmt.Errorf("%w: %d bytes is shorter than the header", ErrInvalidFrame, length)
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return Frame{}, err
+	}
+	f := Frame{
+		Kind:      FrameKind(buf[0]),
+		Command:   binary.BigEndian.Uint16(buf[1:3]),
+		RequestID: binary.BigEndian.Uint32(buf[3:7]),
+		Payload:   buf[headerSize:],
+	}
+	if f.Kind < FrameRequest || f.Kind > FrameEvent {
+		return Frame{}, fmt.Errorf("%w: unknown kind %d", ErrInvalidFrame, f.Kind)
+	}
+	return f, nil
+}
@@ -0,0 +1,114 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	frames := []Frame{
+		{Kind: FrameRequest, Command: 1, RequestID: 7, Payload: []byte(`{"key": "a b c"}`)},
+		{Kind: FrameResponse, Command: 4, RequestID: 1 << 31, Payload: []byte{}},
+		{Kind: FrameEvent, Command: 11, RequestID: 3, Payload: bytes.Repeat([]byte("x"), 1<<17)},
+	}
+	var buf bytes.Buffer
+	for _, f := range frames {
+		assert.NoError(t, WriteFrame(&buf, f))
+	}
+	for _, expected := range frames {
+		f, err := ReadFrame(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, f, "frame should survive the round trip")
+	}
+	_, err := ReadFrame(&buf)
+	assert.ErrorIs(t, err, io.EOF, "reading past the last frame should return EOF")
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], MaxFrameSize+1)
+	_, err := ReadFrame(bytes.NewReader(prefix[:]))
+	assert.ErrorIs(t, err, ErrFrameTooLarge)
+
+	binary.BigEndian.PutUint32(prefix[:], 2)
+	_, err = ReadFrame(bytes.NewReader(append(prefix[:], 1, 2)))
+	assert.ErrorIs(t, err, ErrInvalidFrame, "frames shorter than the header should be rejected")
+
+	var buf bytes.Buffer
+	WriteFrame(&buf, Frame{Kind: FrameRequest, Command: 1, Payload: []byte("payload")})
+	_, err = ReadFrame(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "a truncated frame should be reported")
+
+	buf.Reset()
+	WriteFrame(&buf, Frame{Kind: 42})
+	_, err = ReadFrame(&buf)
+	assert.ErrorIs(t, err, ErrInvalidFrame, "unknown kinds should be rejected")
+
+	err = WriteFrame(io.Discard, Frame{Kind: FrameRequest, Payload: make([]byte, MaxFrameSize)})
+	assert.ErrorIs(t, err, ErrFrameTooLarge)
+}
+
+func TestCommandIDs(t *testing.T) {
+	seen := make(map[uint16]bool)
+	for name, id := range commandIDs {
+		assert.False(t, seen[id], "id %d is used twice", id)
+		seen[id] = true
+		back, ok := CommandName(id)
+		assert.True(t, ok)
+		assert.Equal(t, name, back)
+	}
+	_, ok := CommandName(0)
+	assert.False(t, ok, "0 is not a command")
+}
+
+func TestHandshake(t *testing.T) {
+	version, ok := ParseHandshake(HandshakeLine(FrameVersion))
+	assert.True(t, ok)
+	assert.Equal(t, FrameVersion, version)
+	_, ok = ParseHandshake(`get {"key":"a"}`)
+	assert.False(t, ok, "commands are not handshakes")
+	_, ok = ParseHandshake("version two")
+	assert.False(t, ok)
+
+	r := bufio.NewReader(strings.NewReader("version 2\n"))
+	var out bytes.Buffer
+	assert.NoError(t, Handshake(r, bufio.NewWriter(&out), FrameVersion))
+	assert.Equal(t, "version 2\n", out.String())
+
+	r = bufio.NewReader(strings.NewReader("error: unsupported protocol version 2\n"))
+	assert.Error(t, Handshake(r, bufio.NewWriter(io.Discard), FrameVersion), "a refused handshake should fail")
+}
+
+func TestReadLine(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("put {\"key\": \"a\"}\r\nlist\n" + strings.Repeat("x", 1<<17)))
+	line, err := ReadLine(r)
+	assert.NoError(t, err)
+	assert.Equal(t, `put {"key": "a"}`, line)
+	line, _ = ReadLine(r)
+	assert.Equal(t, "list", line)
+	line, err = ReadLine(r)
+	assert.NoError(t, err, "lines longer than 64KB and without a line ending should be read")
+	assert.Len(t, line, 1<<17)
+	_, err = ReadLine(r)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestReadLineTooLong(t *testing.T) {
+	long := strings.Repeat("x", MaxFrameSize+1)
+	r := bufio.NewReader(strings.NewReader(long + "\nlist\n" + long))
+	_, err := ReadLine(r)
+	assert.ErrorIs(t, err, ErrLineTooLong)
+	line, err := ReadLine(r)
+	assert.NoError(t, err, "the line after a long one should be read")
+	assert.Equal(t, "list", line)
+	_, err = ReadLine(r)
+	assert.ErrorIs(t, err, ErrLineTooLong, "a long last line should be reported too")
+	_, err = ReadLine(r)
+	assert.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,137 @@
+package protocol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	cmds "hw12/internal/commands"
+)
+
+// Connections start in the line protocol, where every request is a
+// `<command> <payload>` line. A client switches to frames by sending
+// `version 2` as its first line, the server confirms with the same line.
+//
+// Framed requests may be pipelined. The server answers them as they finish,
+// so responses have to be matched by request id, and requests in flight at
+// the same time may run in any order. Transaction, watch, collection and
+// index commands, and everything sent while a transaction is open, run one at a
+// time in the order they were sent.
+const (
+	LineVersion  = 1
+	FrameVersion = 2
+)
+
+const handshakePrefix = "version "
+
+// Ids are part of the protocol, never reuse or renumber them.
+var commandIDs = map[string]uint16{
+	cmds.PutCommandName:                1,
+	cmds.GetCommandName:                2,
+	cmds.DeleteCommandName:             3,
+	cmds.ListCommandName:               4,
+	cmds.SelectCommandName:             5,
+	cmds.AggregateCommandName:          6,
+	cmds.UpdateCommandName:             7,
+	cmds.BeginCommandName:              8,
+	cmds.CommitCommandName:             9,
+	cmds.RollbackCommandName:           10,
+	cmds.WatchCommandName:              11,
+	cmds.UnwatchCommandName:            12,
+	cmds.CreateCollectionCommandName:   13,
+	cmds.DropCollectionCommandName:     14,
+	cmds.ListCollectionsCommandName:    15,
+	cmds.DescribeCollectionCommandName: 16,
+	cmds.QueryCommandName:              17,
+	cmds.CreateIndexCommandName:        18,
+	cmds.DropIndexCommandName:          19,
+}
+
+var commandNames = func() map[uint16]string {
+	names := make(map[uint16]string, len(commandIDs))
+	for name, id := range commandIDs {
+		names[id] = name
+	}
+	return names
+}()
+
+func CommandID(name string) (uint16, bool) {
+	id, ok := commandIDs[name]
+	return id, ok
+}
+
+func CommandName(id uint16) (string, bool) {
+	name, ok := commandNames[id]
+	return name, ok
+}
+
+func HandshakeLine(version int) string {
+	return handshakePrefix + strconv.Itoa(version)
+}
+
+// ParseHandshake returns false if the line isn't a handshake, e.g. when it's
+// the first command of a line protocol client.
+func ParseHandshake(line string) (int, bool) {
+	if !strings.HasPrefix(line, handshakePrefix) {
+		return 0, false
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(line, handshakePrefix))
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+// ErrLineTooLong is returned for lines over MaxFrameSize, the same limit as
+// for frames.
+var ErrLineTooLong = errors.New("line too long")
+
+// ReadLine reads a line protocol line without its line ending. A line over
+// MaxFrameSize is read to its end without being kept and ErrLineTooLong is
+// returned, so the next call reads the line after it.
+func ReadLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLong && len(line)+len(chunk) > MaxFrameSize {
+			tooLong = true
+			line = nil
+		}
+		if !tooLong {
+			line = append(line, chunk...)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if tooLong && (err == nil || errors.Is(err, io.EOF)) {
+			return "", fmt.Errorf("%w: over %d bytes", ErrLineTooLong, MaxFrameSize)
+		}
+		// A last line without a line ending still counts
+		if err != nil && !(errors.Is(err, io.EOF) && len(line) > 0) {
+			return "", err
+		}
+		return strings.TrimRight(string(line), "\r\n"), nil
+	}
+}
+
+// Handshake asks the server to switch the connection to the given version.
+func Handshake(r *bufio.Reader, w *bufio.Writer, version int) error {
+	if _, err := w.WriteString(HandshakeLine(version) + "\n"); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	line, err := ReadLine(r)
+	if err != nil {
+		return fmt.Errorf("error reading handshake: %w", err)
+	}
+	if line != HandshakeLine(version) {
+		return fmt.Errorf("server refused protocol version %d: %s", version, line)
+	}
+	return nil
+}
@@ -0,0 +1,532 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	cmds "hw12/internal/commands"
+	store "hw12/internal/documentstore"
+)
+
+// documents is what `put`, `get` and `delete` need, implemented by
+// collections and by transactions.
+type documents interface {
+	Put(doc store.Document) (uint64, error)
+	PutIf(doc store.Document, revision uint64) (uint64, error)
+	Get(key string) (*store.Document, error)
+	Delete(key string) error
+	DeleteIf(key string, revision uint64) error
+}
+
+type txDocuments struct {
+	tx         *store.Tx
+	collection string
+}
+
+// The new revision is only known after the commit, so 0 is returned.
+func (t txDocuments) Put(doc store.Document) (uint64, error) {
+	return 0, t.tx.Put(t.collection, doc)
+}
+
+// As with Put, 0 is returned.
+func (t txDocuments) PutIf(doc store.Document, revision uint64) (uint64, error) {
+	return 0, t.tx.PutIf(t.collection, doc, revision)
+}
+
+func (t txDocuments) Get(key string) (*store.Document, error) {
+	return t.tx.Get(t.collection, key)
+}
+
+func (t txDocuments) Delete(key string) error {
+	return t.tx.Delete(t.collection, key)
+}
+
+func (t txDocuments) DeleteIf(key string, revision uint64) error {
+	return t.tx.DeleteIf(t.collection, key, revision)
+}
+
+// isDocumentCommand reports whether the command works on a collection.
+func isDocumentCommand(name string) bool {
+	switch name {
+	case cmds.PutCommandName, cmds.GetCommandName, cmds.DeleteCommandName, cmds.ListCommandName,
+		cmds.SelectCommandName, cmds.AggregateCommandName, cmds.UpdateCommandName, cmds.WatchCommandName,
+		cmds.QueryCommandName, cmds.CreateIndexCommandName, cmds.DropIndexCommandName:
+		return true
+	}
+	return false
+}
+
+// resolveCollection finds the collection named in a document command payload.
+func (srv *Server) resolveCollection(raw string) (string, *store.Collection, error) {
+	p := &struct {
+		Collection string `json:"collection"`
+	}{}
+	if raw != "" {
+		err := json.Unmarshal([]byte(raw), p)
+		if err != nil {
+			return "", nil, fmt.Errorf("error unmarshalling payload: %w", err)
+		}
+	}
+	if p.Collection == "" {
+		p.Collection = DefaultCollection
+	}
+	col, err := srv.store.GetCollection(p.Collection)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.Collection, col, nil
+}
+
+func (srv *Server) execCreateCollection(raw string) (string, error) {
+	p := &cmds.CreateCollectionCommandRequestPayload{}
+	err := json.Unmarshal([]byte(raw), p)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshalling payload: %w", err)
+	}
+	if p.Name == "" || p.PrimaryKey == "" {
+		return "", errors.New("collection needs a name and a primary key")
+	}
+	cfg := store.CollectionConfig{PrimaryKey: p.PrimaryKey}
+	if p.TTL != "" {
+		cfg.TTL, err = time.ParseDuration(p.TTL)
+		if err != nil {
+			return "", fmt.Errorf("error parsing ttl: %w", err)
+		}
+	}
+	_, err = srv.store.CreateCollection(p.Name, &cfg)
+	if err != nil {
+		return "", fmt.Errorf("error creating collection: %w", err)
+	}
+
+	rawResp, err := json.Marshal(&cmds.CreateCollectionCommandResponsePayload{Ok: true})
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+func (srv *Server) execDropCollection(raw string) (string, error) {
+	p := &cmds.DropCollectionCommandRequestPayload{}
+	err := json.Unmarshal([]byte(raw), p)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshalling payload: %w", err)
+	}
+
+	resp := &cmds.DropCollectionCommandResponsePayload{Ok: true}
+	err = srv.store.DeleteCollection(p.Name)
+	if errors.Is(err, store.ErrCollectionNotFound) {
+		resp.Ok = false
+	} else if err != nil {
+		return "", fmt.Errorf("error dropping collection: %w", err)
+	}
+
+	rawResp, err := json.Marshal(resp)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+func (srv *Server) execListCollections() (string, error) {
+	rawResp, err := json.Marshal(&cmds.ListCollectionsCommandResponsePayload{
+		Value: srv.store.ListCollections(),
+		Ok:    true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+func (srv *Server) execDescribeCollection(raw string) (string, error) {
+	p := &cmds.DescribeCollectionCommandRequestPayload{}
+	err := json.Unmarshal([]byte(raw), p)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshalling payload: %w", err)
+	}
+
+	resp := &cmds.DescribeCollectionCommandResponsePayload{}
+	col, err := srv.store.GetCollection(p.Name)
+	if err != nil && !errors.Is(err, store.ErrCollectionNotFound) {
+		return "", fmt.Errorf("error getting collection: %w", err)
+	}
+	if err == nil {
+		info := col.Info()
+		resp.Name = info.Name
+		resp.PrimaryKey = info.Config.PrimaryKey
+		if info.Config.TTL > 0 {
+			resp.TTL = info.Config.TTL.String()
+		}
+		resp.Documents = info.Documents
+		resp.Indexes = info.Indexes
+		resp.UniqueIndexes = info.UniqueIndexes
+		resp.Ok = true
+	}
+
+	rawResp, err := json.Marshal(resp)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+func execPut(raw string, col documents) (string, error) {
+	p := &cmds.PutCommandRequestPayload{}
+	err := json.Unmarshal([]byte(raw), p)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshalling payload: %w", err)
+	}
+	err = p.Document.Validate()
+	if err != nil {
+		return "", fmt.Errorf("error validating document: %w", err)
+	}
+	resp := &cmds.PutCommandResponsePayload{}
+	if p.Revision != nil {
+		resp.Revision, err = col.PutIf(p.Document, *p.Revision)
+	} else {
+		resp.Revision, err = col.Put(p.Document)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error putting document: %w", err)
+	}
+
+	rawResp, err := json.Marshal(resp)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+func execGet(raw string, col documents) (string, error) {
+	p := &cmds.GetCommandRequestPayload{}
+	err := json.Unmarshal([]byte(raw), p)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshalling payload: %w", err)
+	}
+
+	doc, err := col.Get(p.Key)
+	if err != nil && !errors.Is(err, store.ErrDocumentNotFound) {
+		return "", fmt.Errorf("error getting document: %w", err)
+	}
+	resp := &cmds.GetCommandResponsePayload{Ok: err == nil}
+	if err == nil {
+		resp.Document = doc
+		resp.Revision = doc.Revision
+	}
+
+	rawResp, err := json.Marshal(resp)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+func execDelete(raw string, col documents) (string, error) {
+	p := &cmds.DeleteCommandRequestPayload{}
+	err := json.Unmarshal([]byte(raw), p)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshalling payload: %w", err)
+	}
+
+	if p.Revision != nil {
+		err = col.DeleteIf(p.Key, *p.Revision)
+	} else {
+		err = col.Delete(p.Key)
+	}
+	if err != nil && !errors.Is(err, store.ErrDocumentNotFound) {
+		return "", fmt.Errorf("error deleting document: %w", err)
+	}
+	resp := &cmds.DeleteCommandResponsePayload{
+		Ok: err == nil,
+	}
+
+	rawResp, err := json.Marshal(resp)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+func execList(raw string, col *store.Collection) (string, error) {
+	p := &cmds.ListCommandRequestPayload{}
+	if raw != "" {
+		err := json.Unmarshal([]byte(raw), p)
+		if err != nil {
+			return "", fmt.Errorf("error unmarshalling payload: %w", err)
+		}
+	}
+
+	page, err := col.ListPage(store.PageOptions{Limit: p.Limit, Cursor: p.Cursor})
+	if err != nil {
+		return "", fmt.Errorf("error listing documents: %w", err)
+	}
+	if page.Documents == nil {
+		page.Documents = []store.Document{}
+	}
+
+	resp := &cmds.ListCommandResponsePayload{
+		Value:      page.Documents,
+		Ok:         true,
+		NextCursor: page.NextCursor,
+	}
+
+	rawResp, err := json.Marshal(resp)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+func execQuery(raw string, col *store.Collection) (string, error) {
+	p := &cmds.QueryCommandRequestPayload{}
+	err := json.Unmarshal([]byte(raw), p)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshalling payload: %w", err)
+	}
+
+	docs, err := col.Query(p.Field, p.Params)
+	if err != nil {
+		return "", fmt.Errorf("error querying documents: %w", err)
+	}
+	if docs == nil {
+		docs = []store.Document{}
+	}
+
+	rawResp, err := json.Marshal(&cmds.QueryCommandResponsePayload{Value: docs, Ok: true})
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+func execCreateIndex(raw string, col *store.Collection) (string, error) {
+	p := &cmds.CreateIndexCommandRequestPayload{}
+	err := json.Unmarshal([]byte(raw), p)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshalling payload: %w", err)
+	}
+
+	if p.Unique {
+		err = col.CreateUniqueIndex(p.Fields...)
+	} else {
+		err = col.CreateIndex(p.Fields...)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error creating index: %w", err)
+	}
+
+	rawResp, err := json.Marshal(&cmds.CreateIndexCommandResponsePayload{Ok: true})
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+func execDropIndex(raw string, col *store.Collection) (string, error) {
+	p := &cmds.DropIndexCommandRequestPayload{}
+	err := json.Unmarshal([]byte(raw), p)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshalling payload: %w", err)
+	}
+
+	resp := &cmds.DropIndexCommandResponsePayload{Ok: true}
+	err = col.DeleteIndex(p.Fields...)
+	if errors.Is(err, store.ErrIndexNotFound) {
+		resp.Ok = false
+	} else if err != nil {
+		return "", fmt.Errorf("error dropping index: %w", err)
+	}
+
+	rawResp, err := json.Marshal(resp)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+func execSelect(raw string, col *store.Collection) (string, error) {
+	p := &cmds.SelectCommandRequestPayload{}
+	if raw != "" {
+		err := json.Unmarshal([]byte(raw), p)
+		if err != nil {
+			return "", fmt.Errorf("error unmarshalling payload: %w", err)
+		}
+	}
+
+	opts := store.SelectOptions{Fields: p.Fields, Limit: p.Limit, Offset: p.Offset}
+	for _, f := range p.Sort {
+		opts.Sort = append(opts.Sort, store.SortField{Path: f.Field, Desc: f.Desc})
+	}
+	docs, err := col.Select(opts)
+	if err != nil {
+		return "", fmt.Errorf("error selecting documents: %w", err)
+	}
+	resp := &cmds.SelectCommandResponsePayload{
+		Value: docs,
+		Ok:    true,
+	}
+
+	rawResp, err := json.Marshal(resp)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+func execAggregate(raw string, col *store.Collection) (string, error) {
+	p := &cmds.AggregateCommandRequestPayload{}
+	err := json.Unmarshal([]byte(raw), p)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshalling payload: %w", err)
+	}
+
+	opts := store.AggregateOptions{GroupBy: p.GroupBy}
+	for _, a := range p.Aggregations {
+		opts.Aggregations = append(opts.Aggregations, store.Aggregation{Op: store.AggregateOp(a.Op), Path: a.Field, As: a.As})
+	}
+	groups, err := col.Aggregate(opts)
+	if err != nil {
+		return "", fmt.Errorf("error aggregating documents: %w", err)
+	}
+	values := make([]cmds.AggregateGroup, len(groups))
+
+	for i, g := range groups {
+		values[i] = cmds.AggregateGroup{Key: g.Key, Values: g.Values}
+	}
+
+	resp := &cmds.AggregateCommandResponsePayload{
+		Value: values,
+		Ok:    true,
+	}
+
+	rawResp, err := json.Marshal(resp)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+// fieldFromJSON picks the document field type of a decoded JSON value.
+func fieldFromJSON(value interface{}) *store.DocumentField {
+	switch value.(type) {
+	case nil:
+		return nil
+	case string:
+		return &store.DocumentField{Type: store.DocumentFieldTypeString, Value: value}
+	case float64:
+		return &store.DocumentField{Type: store.DocumentFieldTypeNumber, Value: value}
+	case bool:
+		return &store.DocumentField{Type: store.DocumentFieldTypeBool, Value: value}
+	case []interface{}:
+		return &store.DocumentField{Type: store.DocumentFieldTypeArray, Value: value}
+	}
+	return &store.DocumentField{Type: store.DocumentFieldTypeObject, Value: value}
+}
+
+func execUpdate(raw string, col *store.Collection) (string, error) {
+	p := &cmds.UpdateCommandRequestPayload{}
+	err := json.Unmarshal([]byte(raw), p)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshalling payload: %w", err)
+	}
+
+	ops := make([]store.UpdateOp, len(p.Ops))
+	for i, op := range p.Ops {
+		ops[i] = store.UpdateOp{Op: store.UpdateOpType(op.Op), Path: op.Field, Value: fieldFromJSON(op.Value)}
+	}
+	doc, err := col.Update(p.Key, ops...)
+	if err != nil && !errors.Is(err, store.ErrDocumentNotFound) {
+		return "", fmt.Errorf("error updating document: %w", err)
+	}
+	resp := &cmds.UpdateCommandResponsePayload{
+		Ok: err == nil,
+	}
+	if err == nil {
+		resp.Revision = doc.Revision
+	}
+
+	rawResp, err := json.Marshal(resp)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+func execTx(ok bool) (string, error) {
+	rawResp, err := json.Marshal(&cmds.TxCommandResponsePayload{Ok: ok})
+	if err != nil {
+		return "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return string(rawResp), nil
+}
+
+func execWatch(raw string, col *store.Collection) (*store.Watcher, string, error) {
+	p := &cmds.WatchCommandRequestPayload{}
+	if raw != "" {
+		err := json.Unmarshal([]byte(raw), p)
+		if err != nil {
+			return nil, "", fmt.Errorf("error unmarshalling payload: %w", err)
+		}
+	}
+	var filter *store.Filter
+	if len(p.Filter) > 0 {
+		filter = &store.Filter{}
+		err := json.Unmarshal(p.Filter, filter)
+		if err != nil {
+			return nil, "", fmt.Errorf("error unmarshalling filter: %w", err)
+		}
+	}
+
+	watcher, err := col.Watch(filter, p.Buffer)
+	if err != nil {
+		return nil, "", fmt.Errorf("error watching collection: %w", err)
+	}
+
+	rawResp, err := json.Marshal(&cmds.WatchCommandResponsePayload{Ok: true})
+	if err != nil {
+		watcher.Close()
+		return nil, "", fmt.Errorf("error marshalling response: %w", err)
+	}
+
+	return watcher, string(rawResp), nil
+}
+
+// streamEvents passes events to send until the watcher is closed.
+func streamEvents(watcher *store.Watcher, send func(rawEvent []byte), done chan struct{}) {
+	defer close(done)
+	for e := range watcher.Events() {
+		rawEvent, err := json.Marshal(&cmds.WatchEventPayload{
+			Type:     string(e.Type),
+			Key:      e.Key,
+			Revision: e.Revision,
+			Before:   e.Before,
+			After:    e.After,
+		})
+		if err != nil {
+			fmt.Println(fmt.Errorf("error marshalling event: %w", err))
+			continue
+		}
+		send(rawEvent)
+	}
+	if err := watcher.Err(); err != nil {
+		rawEvent, _ := json.Marshal(&cmds.WatchEventPayload{Error: err.Error()})
+		send(rawEvent)
+	}
+}
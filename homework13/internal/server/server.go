@@ -0,0 +1,330 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	cmds "hw12/internal/commands"
+	store "hw12/internal/documentstore"
+	"hw12/internal/protocol"
+)
+
+// Document commands without a collection in their payload use this one
+const DefaultCollection = "key"
+const maxInFlight = 64 // Concurrent requests per framed connection
+
+// Server runs the commands of its connections against one store.
+type Server struct {
+	store *store.Store
+}
+
+func New(s *store.Store) *Server {
+	return &Server{store: s}
+}
+
+// session is the state of one connection, the same for both protocol versions.
+type session struct {
+	srv *Server
+	// Transaction opened with `begin`, dropped if the connection closes before `commit`
+	tx        *store.Tx
+	watcher   *store.Watcher
+	watchDone chan struct{}
+}
+
+func (c *session) stopWatch() {
+	if c.watcher != nil {
+		c.watcher.Close()
+		<-c.watchDone
+		c.watcher = nil
+	}
+}
+
+// sequential reports whether the command depends on or changes the
+// connection's state, so it can't overlap with other requests.
+func (c *session) sequential(name string) bool {
+	if c.tx != nil {
+		return true
+	}
+	switch name {
+	case cmds.BeginCommandName, cmds.CommitCommandName, cmds.RollbackCommandName,
+		cmds.WatchCommandName, cmds.UnwatchCommandName,
+		cmds.CreateCollectionCommandName, cmds.DropCollectionCommandName,
+		cmds.CreateIndexCommandName, cmds.DropIndexCommandName:
+		return true
+	}
+	return false
+}
+
+func (c *session) close() {
+	c.stopWatch()
+	if c.tx != nil {
+		c.tx.Rollback()
+	}
+}
+
+// exec runs the command and passes its result to respond. Events of a
+// successful `watch` go to event, the first one after the response. It
+// returns false for unknown commands without responding.
+func (c *session) exec(name string, payload string, respond func(resp string, err error), event func(rawEvent []byte)) bool {
+	var resp string
+	var err error
+	var col *store.Collection
+	var docs documents
+	if isDocumentCommand(name) {
+		var colName string
+		colName, col, err = c.srv.resolveCollection(payload)
+		if err != nil {
+			respond("", err)
+			return true
+		}
+		docs = col
+		if c.tx != nil {
+			docs = txDocuments{tx: c.tx, collection: colName}
+		}
+	}
+
+	switch name {
+	case cmds.PutCommandName:
+		resp, err = execPut(payload, docs)
+	case cmds.GetCommandName:
+		resp, err = execGet(payload, docs)
+	case cmds.DeleteCommandName:
+		resp, err = execDelete(payload, docs)
+	case cmds.BeginCommandName:
+		if c.tx != nil {
+			err = errors.New("transaction already started")
+			break
+		}
+		c.tx = c.srv.store.Begin()
+		resp, err = execTx(true)
+	case cmds.CommitCommandName:
+		if c.tx == nil {
+			err = errors.New("no transaction started")
+			break
+		}
+		err = c.tx.Commit()
+		c.tx = nil
+		if err != nil {
+			err = fmt.Errorf("error committing transaction: %w", err)
+			break
+		}
+		resp, err = execTx(true)
+	case cmds.RollbackCommandName:
+		if c.tx == nil {
+			err = errors.New("no transaction started")
+			break
+		}
+		c.tx.Rollback()
+		c.tx = nil
+		resp, err = execTx(true)
+	case cmds.ListCommandName:
+		resp, err = execList(payload, col)
+	case cmds.SelectCommandName:
+		resp, err = execSelect(payload, col)
+	case cmds.AggregateCommandName:
+		resp, err = execAggregate(payload, col)
+	case cmds.UpdateCommandName:
+		if c.tx != nil {
+			err = errors.New("update is not supported in a transaction")
+			break
+		}
+		resp, err = execUpdate(payload, col)
+	case cmds.WatchCommandName:
+		select {
+		case <-c.watchDone:
+			// The previous watch was stopped by the server
+			c.stopWatch()
+		default:
+		}
+		if c.watcher != nil {
+			err = errors.New("already watching")
+			break
+		}
+		c.watcher, resp, err = execWatch(payload, col)
+		if err == nil {
+			// The response has to go out before the first event
+			respond(resp, nil)
+			c.watchDone = make(chan struct{})
+			go streamEvents(c.watcher, event, c.watchDone)
+			return true
+		}
+	case cmds.UnwatchCommandName:
+		if c.watcher == nil {
+			err = errors.New("not watching")
+			break
+		}
+		c.stopWatch()
+		rawResp, _ := json.Marshal(&cmds.WatchCommandResponsePayload{Ok: true})
+		resp = string(rawResp)
+	case cmds.QueryCommandName:
+		resp, err = execQuery(payload, col)
+	case cmds.CreateIndexCommandName:
+		resp, err = execCreateIndex(payload, col)
+	case cmds.DropIndexCommandName:
+		resp, err = execDropIndex(payload, col)
+	case cmds.CreateCollectionCommandName:
+		resp, err = c.srv.execCreateCollection(payload)
+	case cmds.DropCollectionCommandName:
+		resp, err = c.srv.execDropCollection(payload)
+	case cmds.ListCollectionsCommandName:
+		resp, err = c.srv.execListCollections()
+	case cmds.DescribeCollectionCommandName:
+		resp, err = c.srv.execDescribeCollection(payload)
+	default:
+		return false
+	}
+
+	respond(resp, err)
+	return true
+}
+
+// HandleConnection serves the connection until it closes. It starts with the
+// line protocol, which the client may switch to frames with a handshake.
+func (srv *Server) HandleConnection(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	// Watch events are written from another goroutine
+	var wmx sync.Mutex
+	writeLine := func(line string) {
+		wmx.Lock()
+		defer wmx.Unlock()
+		w.WriteString(line + "\n")
+		w.Flush()
+	}
+	writeFrame := func(f protocol.Frame) {
+		wmx.Lock()
+		defer wmx.Unlock()
+		err := protocol.WriteFrame(w, f)
+		if errors.Is(err, protocol.ErrFrameTooLarge) && f.Kind != protocol.FrameError {
+			// Nothing was written, so the client can still be told why it
+			// won't get the response
+			err = protocol.WriteFrame(w, errorFrame(f, err))
+		}
+		if err != nil {
+			fmt.Println(fmt.Errorf("error writing frame: %w", err))
+		}
+		w.Flush()
+	}
+
+	c := &session{srv: srv}
+	defer c.close()
+
+	if serveLines(r, c, writeLine) {
+		serveFrames(r, c, writeFrame)
+	}
+
+	fmt.Println("connection closed")
+}
+
+// serveLines runs the line protocol until the connection closes or the client
+// switches to frames, which is only possible before its first command.
+func serveLines(r *bufio.Reader, c *session, writeLine func(string)) bool {
+	respond := func(resp string, err error) {
+		if err != nil {
+			writeLine(fmt.Sprintf("error: %s", err))
+		} else {
+			writeLine(fmt.Sprintf("response: %s", resp))
+		}
+	}
+	event := func(rawEvent []byte) {
+		writeLine(fmt.Sprintf("event: %s", rawEvent))
+	}
+
+	started := false
+	for {
+		msg, err := protocol.ReadLine(r)
+		if errors.Is(err, protocol.ErrLineTooLong) {
+			started = true
+			respond("", err)
+			continue
+		}
+		if err != nil {
+			return false
+		}
+
+		if version, ok := protocol.ParseHandshake(msg); ok && !started {
+			switch version {
+			case protocol.LineVersion:
+				writeLine(protocol.HandshakeLine(version))
+			case protocol.FrameVersion:
+				writeLine(protocol.HandshakeLine(version))
+				return true
+			default:
+				writeLine(fmt.Sprintf("error: unsupported protocol version %d", version))
+			}
+			continue
+		}
+		started = true
+
+		// The payload is everything after the first space, `list`, `select`
+		// and `list_collections` may come without one
+		name, payload, _ := strings.Cut(msg, " ")
+		if !c.exec(name, payload, respond, event) {
+			writeLine("invalid command")
+		}
+	}
+}
+
+// serveFrames runs the framed protocol until the connection closes or sends
+// a frame that can't be read. Requests run concurrently and responses go out
+// as they are ready, except for sequential ones, which wait for every earlier
+// request and block later ones until they are done.
+func serveFrames(r *bufio.Reader, c *session, writeFrame func(protocol.Frame)) {
+	var inFlight sync.WaitGroup
+	defer inFlight.Wait()
+	slots := make(chan struct{}, maxInFlight)
+
+	for {
+		f, err := protocol.ReadFrame(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				// The stream can't be resynchronized after a bad frame
+				writeFrame(errorFrame(protocol.Frame{}, err))
+			}
+			return
+		}
+
+		respond := func(resp string, err error) {
+			if err != nil {
+				writeFrame(errorFrame(f, err))
+				return
+			}
+			writeFrame(protocol.Frame{Kind: protocol.FrameResponse, Command: f.Command, RequestID: f.RequestID, Payload: []byte(resp)})
+		}
+		event := func(rawEvent []byte) {
+			writeFrame(protocol.Frame{Kind: protocol.FrameEvent, Command: f.Command, RequestID: f.RequestID, Payload: rawEvent})
+		}
+
+		name, ok := protocol.CommandName(f.Command)
+		if f.Kind != protocol.FrameRequest || !ok {
+			respond("", errors.New("invalid command"))
+			continue
+		}
+		if c.sequential(name) {
+			inFlight.Wait()
+			c.exec(name, string(f.Payload), respond, event)
+			continue
+		}
+		slots <- struct{}{}
+		inFlight.Add(1)
+		go func() {
+			defer inFlight.Done()
+			defer func() { <-slots }()
+			c.exec(name, string(f.Payload), respond, event)
+		}()
+	}
+}
+
+// errorFrame answers the request with err, see commands.ErrorPayload.
+func errorFrame(req protocol.Frame, err error) protocol.Frame {
+	payload, _ := json.Marshal(cmds.NewErrorPayload(err))
+	return protocol.Frame{Kind: protocol.FrameError, Command: req.Command, RequestID: req.RequestID, Payload: payload}
+}
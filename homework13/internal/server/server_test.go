@@ -0,0 +1,253 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	cmds "hw12/internal/commands"
+	store "hw12/internal/documentstore"
+	"hw12/internal/protocol"
+)
+
+type request struct {
+	id      uint32
+	command string
+	payload string
+}
+
+// framedConn serves one end of a pipe and returns the other one switched to
+// frames. The pipe has no buffer, so a response is only written once the test
+// reads it.
+func framedConn(t *testing.T, keys ...string) (net.Conn, *bufio.Reader) {
+	s := store.NewStore()
+	col, err := s.CreateCollection(DefaultCollection, &store.CollectionConfig{PrimaryKey: "key"})
+	assert.NoError(t, err)
+	for _, key := range keys {
+		_, err = col.Put(document(key))
+		assert.NoError(t, err)
+	}
+
+	serverConn, conn := net.Pipe()
+	go New(s).HandleConnection(serverConn)
+	t.Cleanup(func() { conn.Close() })
+
+	r := bufio.NewReader(conn)
+	assert.NoError(t, protocol.Handshake(r, bufio.NewWriter(conn), protocol.FrameVersion))
+	return conn, r
+}
+
+func document(key string) store.Document {
+	return store.Document{Fields: map[string]store.DocumentField{
+		"key": {Type: store.DocumentFieldTypeString, Value: key},
+	}}
+}
+
+func getRequest(id uint32, key string) request {
+	return request{id: id, command: cmds.GetCommandName, payload: fmt.Sprintf(`{"key":%q}`, key)}
+}
+
+// send writes the requests in the background, as the server stops reading
+// while it waits for responses to be read. sent counts the frames written.
+func send(t *testing.T, conn net.Conn, requests []request, sent *atomic.Int32) {
+	go func() {
+		for _, req := range requests {
+			command, _ := protocol.CommandID(req.command)
+			err := protocol.WriteFrame(conn, protocol.Frame{
+				Kind:      protocol.FrameRequest,
+				Command:   command,
+				RequestID: req.id,
+				Payload:   []byte(req.payload),
+			})
+			if err != nil {
+				return
+			}
+			if sent != nil {
+				sent.Add(1)
+			}
+		}
+	}()
+}
+
+func readResponse(t *testing.T, r *bufio.Reader) protocol.Frame {
+	f, err := protocol.ReadFrame(r)
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.FrameResponse, f.Kind, "unexpected frame %s", f.Payload)
+	return f
+}
+
+func documentKey(t *testing.T, f protocol.Frame) string {
+	resp := &cmds.GetCommandResponsePayload{}
+	assert.NoError(t, json.Unmarshal(f.Payload, resp))
+	if !resp.Ok || resp.Document == nil {
+		return ""
+	}
+	key, _ := resp.Document.Fields["key"].Value.(string)
+	return key
+}
+
+func TestServeFramesMatchesResponsesToRequestIDs(t *testing.T) {
+	keys := make(map[uint32]string)
+	var requests []request
+	// Ids are neither ordered nor contiguous
+	for i := 20; i > 0; i-- {
+		id := uint32(i * 7919 % 1000)
+		keys[id] = fmt.Sprintf("key%d", i)
+		requests = append(requests, getRequest(id, keys[id]))
+	}
+	conn, r := framedConn(t, "key1", "key5", "key12", "key20")
+
+	send(t, conn, requests, nil)
+	for range requests {
+		f := readResponse(t, r)
+		key, ok := keys[f.RequestID]
+		assert.True(t, ok, "response to an unknown request id %d", f.RequestID)
+		delete(keys, f.RequestID)
+		switch key {
+		case "key1", "key5", "key12", "key20":
+			assert.Equal(t, key, documentKey(t, f), "request %d should get the document it asked for", f.RequestID)
+		default:
+			assert.Empty(t, documentKey(t, f), "request %d asked for a missing document", f.RequestID)
+		}
+	}
+	assert.Empty(t, keys, "every request should get a response")
+}
+
+func TestServeFramesBeginWaitsForInFlightRequests(t *testing.T) {
+	const gets = 20
+	conn, r := framedConn(t, "a")
+
+	var requests []request
+	for i := 1; i <= gets; i++ {
+		requests = append(requests, getRequest(uint32(i), "a"))
+	}
+	requests = append(requests, request{id: gets + 1, command: cmds.BeginCommandName})
+	send(t, conn, requests, nil)
+
+	// The gets can't finish before their responses are read, which only
+	// starts once `begin` was sent
+	for i := 0; i < gets; i++ {
+		f := readResponse(t, r)
+		assert.NotEqual(t, uint32(gets+1), f.RequestID, "begin should wait for the requests before it")
+	}
+	f := readResponse(t, r)
+	assert.Equal(t, uint32(gets+1), f.RequestID)
+}
+
+func TestServeFramesRunsRequestsInOrderInTx(t *testing.T) {
+	const puts = 20
+	conn, r := framedConn(t)
+
+	requests := []request{{id: 1, command: cmds.BeginCommandName}}
+	for i := 0; i < puts; i++ {
+		key := fmt.Sprintf("key%d", i)
+		put, _ := json.Marshal(&cmds.PutCommandRequestPayload{Document: document(key)})
+		id := uint32(len(requests) + 1)
+		requests = append(requests,
+			request{id: id, command: cmds.PutCommandName, payload: string(put)},
+			getRequest(id+1, key))
+	}
+	requests = append(requests, request{id: uint32(len(requests) + 1), command: cmds.CommitCommandName})
+	var sent atomic.Int32
+	send(t, conn, requests, &sent)
+
+	// With a transaction open the server reads the next request only after
+	// the response to the previous one went out
+	assert.Equal(t, requests[0].id, readResponse(t, r).RequestID)
+	assert.Eventually(t, func() bool { return sent.Load() == 2 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), sent.Load(), "requests in a transaction should not be read ahead")
+
+	for _, req := range requests[1:] {
+		f := readResponse(t, r)
+		assert.Equal(t, req.id, f.RequestID, "requests in a transaction should run one after another")
+		if req.command == cmds.GetCommandName {
+			assert.NotEmpty(t, documentKey(t, f), "get should see the put before it")
+		}
+	}
+}
+
+func TestServeFramesLimitsRequestsInFlight(t *testing.T) {
+	conn, r := framedConn(t, "a")
+
+	var requests []request
+	for i := 1; i <= maxInFlight+2; i++ {
+		requests = append(requests, getRequest(uint32(i), "a"))
+	}
+	var sent atomic.Int32
+	send(t, conn, requests, &sent)
+
+	// One more than the limit is read and waits for a slot, the next one
+	// isn't read at all
+	assert.Eventually(t, func() bool { return sent.Load() == maxInFlight+1 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(maxInFlight+1), sent.Load(), "the server should stop reading with every slot taken")
+
+	readResponse(t, r)
+	assert.Eventually(t, func() bool { return sent.Load() == maxInFlight+2 }, time.Second, time.Millisecond,
+		"a response should free a slot")
+	for i := 1; i < maxInFlight+2; i++ {
+		readResponse(t, r)
+	}
+}
+
+func TestServeLinesRejectsLongLines(t *testing.T) {
+	s := store.NewStore()
+	_, err := s.CreateCollection(DefaultCollection, &store.CollectionConfig{PrimaryKey: "key"})
+	assert.NoError(t, err)
+	serverConn, conn := net.Pipe()
+	go New(s).HandleConnection(serverConn)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		conn.Write([]byte(cmds.GetCommandName + " " + strings.Repeat("x", protocol.MaxFrameSize) + "\n"))
+		conn.Write([]byte(cmds.ListCommandName + "\n"))
+	}()
+	r := bufio.NewReader(conn)
+	line, err := protocol.ReadLine(r)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(line, "error: line too long"), "unexpected line %q", line)
+	line, err = protocol.ReadLine(r)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(line, "response: "), "the connection should stay usable, got %q", line)
+}
+
+func TestSelectAndWatchKeepFieldTypes(t *testing.T) {
+	conn, r := framedConn(t, "a")
+
+	send(t, conn, []request{{id: 1, command: cmds.SelectCommandName, payload: "{}"}}, nil)
+	sel := &cmds.SelectCommandResponsePayload{}
+	assert.NoError(t, json.Unmarshal(readResponse(t, r).Payload, sel))
+	assert.Equal(t, []store.Document{document("a")}, clearRevisions(sel.Value))
+
+	put, _ := json.Marshal(&cmds.PutCommandRequestPayload{Document: document("b")})
+	send(t, conn, []request{
+		{id: 2, command: cmds.WatchCommandName, payload: "{}"},
+		{id: 3, command: cmds.PutCommandName, payload: string(put)},
+	}, nil)
+	var event *cmds.WatchEventPayload
+	for event == nil {
+		f, err := protocol.ReadFrame(r)
+		assert.NoError(t, err)
+		if f.Kind == protocol.FrameEvent {
+			event = &cmds.WatchEventPayload{}
+			assert.NoError(t, json.Unmarshal(f.Payload, event))
+		}
+	}
+	assert.Nil(t, event.Before)
+	assert.Equal(t, store.DocumentFieldTypeString, event.After.Fields["key"].Type, "events should keep field types")
+}
+
+func clearRevisions(docs []store.Document) []store.Document {
+	for i := range docs {
+		docs[i].Revision = 0
+	}
+	return docs
+}
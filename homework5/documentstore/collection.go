@@ -1,5 +1,14 @@
 package documentstore
 
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrMissingPrimaryKey = errors.New("document has no primary key field")
+var ErrPrimaryKeyNotString = errors.New("primary key field is not a string")
+var ErrEmptyPrimaryKey = errors.New("primary key is empty")
+
 type Collection struct {
 	docs   map[string]Document
 	config CollectionConfig
@@ -9,15 +18,21 @@ type CollectionConfig struct {
 	PrimaryKey string
 }
 
-func (s *Collection) Put(doc Document) {
+func (s *Collection) Put(doc Document) error {
 	// Потрібно перевірити що документ містить поле `{cfg.PrimaryKey}` типу `string`
 	keyField, ok := doc.Fields[s.config.PrimaryKey]
-	if ok && keyField.Type == DocumentFieldTypeString {
-		key, isString := keyField.Value.(string)
-		if isString && len(key) > 0 {
-			s.docs[key] = doc
-		}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrMissingPrimaryKey, s.config.PrimaryKey)
+	}
+	key, isString := keyField.Value.(string)
+	if keyField.Type != DocumentFieldTypeString || !isString {
+		return fmt.Errorf("%w: %s", ErrPrimaryKeyNotString, s.config.PrimaryKey)
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("%w: %s", ErrEmptyPrimaryKey, s.config.PrimaryKey)
 	}
+	s.docs[key] = doc
+	return nil
 }
 
 func (s *Collection) Get(key string) (*Document, bool) {
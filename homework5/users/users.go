@@ -40,7 +40,10 @@ func (s *Service) CreateUser(name string) (*User, error) {
 	if err != nil {
 		return nil, err
 	}
-	s.coll.Put(*doc)
+	err = s.coll.Put(*doc)
+	if err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 